@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ApdexThreshold defines the default Apdex target threshold (T) used by MeterApdex.
+// Defaults to 500 milliseconds.
+var ApdexThreshold = 500 * time.Millisecond
+
+// Apdex implements an Apdex (Application Performance Index) score metric.
+// Apdex aggregates the number of satisfied, tolerating and frustrated requests
+// in order to expose a single user satisfaction score between 0 and 1.
+//
+// Apdex is designed to be safety used by multiple goroutines.
+type Apdex struct {
+	sync.Mutex
+	satisfied  uint64
+	tolerating uint64
+	frustrated uint64
+}
+
+// Satisfied registers a new satisfied sample.
+func (a *Apdex) Satisfied() {
+	a.Lock()
+	a.satisfied++
+	a.Unlock()
+}
+
+// Tolerating registers a new tolerating sample.
+func (a *Apdex) Tolerating() {
+	a.Lock()
+	a.tolerating++
+	a.Unlock()
+}
+
+// Frustrated registers a new frustrated sample.
+func (a *Apdex) Frustrated() {
+	a.Lock()
+	a.frustrated++
+	a.Unlock()
+}
+
+// Score returns the current Apdex score, defined as:
+//
+//	(satisfied + tolerating / 2) / total
+//
+// Score returns zero if no samples were registered.
+func (a *Apdex) Score() float64 {
+	a.Lock()
+	defer a.Unlock()
+	total := a.satisfied + a.tolerating + a.frustrated
+	if total == 0 {
+		return 0
+	}
+	return (float64(a.satisfied) + float64(a.tolerating)/2) / float64(total)
+}
+
+// ApdexMeter implements an Apdex meter function with a configurable
+// target threshold, which can be optionally overwritten per route.
+type ApdexMeter struct {
+	sync.RWMutex
+	// Key stores the metric key used to report the Apdex score.
+	Key string
+	// threshold stores the default target threshold.
+	threshold time.Duration
+	// routes stores the target thresholds by route path prefix.
+	routes map[string]time.Duration
+}
+
+// NewApdexMeter creates a new Apdex meter with the given default target threshold.
+func NewApdexMeter(threshold time.Duration) *ApdexMeter {
+	return &ApdexMeter{
+		Key:       "res.apdex",
+		threshold: threshold,
+		routes:    make(map[string]time.Duration),
+	}
+}
+
+// SetRoute sets the target threshold to be used for the requests
+// whose URL path starts with the given path.
+// If multiple routes match, the longest one will be used.
+func (a *ApdexMeter) SetRoute(path string, threshold time.Duration) {
+	a.Lock()
+	a.routes[path] = threshold
+	a.Unlock()
+}
+
+// Threshold returns the target threshold to be applied to the given request.
+func (a *ApdexMeter) Threshold(r *http.Request) time.Duration {
+	a.RLock()
+	defer a.RUnlock()
+
+	if r.URL == nil {
+		return a.threshold
+	}
+
+	threshold, match := a.threshold, ""
+	for path, t := range a.routes {
+		if strings.HasPrefix(r.URL.Path, path) && len(path) > len(match) {
+			threshold, match = t, path
+		}
+	}
+	return threshold
+}
+
+// Meter implements the MeterFunc interface.
+func (a *ApdexMeter) Meter(i *Info, m *Metrics) {
+	meterApdex(i, m.Apdex(a.Key), a.Threshold(i.Request))
+}
+
+// MeterApdex is used to measure the Apdex score of the served requests
+// based on the ApdexThreshold target threshold.
+// Use ApdexMeter if you need a custom threshold per route.
+func MeterApdex(i *Info, m *Metrics) {
+	meterApdex(i, m.Apdex("res.apdex"), ApdexThreshold)
+}
+
+// meterApdex classifies the request as satisfied, tolerating or frustrated.
+// Failed responses are always considered as frustrated.
+func meterApdex(i *Info, a *Apdex, threshold time.Duration) {
+	resTime := i.TimeEnd.Sub(i.TimeStart)
+	switch {
+	case i.Status >= 500 || resTime > 4*threshold:
+		a.Frustrated()
+	case resTime > threshold:
+		a.Tolerating()
+	default:
+		a.Satisfied()
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nbio/st"
+)
+
+func TestApdexScore(t *testing.T) {
+	apdex := &Apdex{}
+	st.Expect(t, apdex.Score(), float64(0))
+
+	apdex.Satisfied()
+	apdex.Satisfied()
+	apdex.Tolerating()
+	apdex.Frustrated()
+	st.Expect(t, apdex.Score(), 0.625)
+}
+
+func TestMeterApdex(t *testing.T) {
+	info, metrics := createMetrics()
+	defer metrics.Reset()
+
+	MeterApdex(info, metrics)
+	st.Expect(t, metrics.Snapshot().FloatGauges["res.apdex"], float64(1))
+
+	info.TimeEnd = info.TimeStart.Add(ApdexThreshold + time.Millisecond)
+	MeterApdex(info, metrics)
+	st.Expect(t, metrics.Snapshot().FloatGauges["res.apdex"], 0.75)
+
+	info.TimeEnd = info.TimeStart
+	info.Status = 503
+	MeterApdex(info, metrics)
+	st.Expect(t, metrics.Snapshot().FloatGauges["res.apdex"], 0.5)
+}
+
+func TestApdexMeterRoutes(t *testing.T) {
+	info, metrics := createMetrics()
+	defer metrics.Reset()
+	info.Request.URL = &url.URL{Path: "/api/users"}
+
+	meter := NewApdexMeter(time.Second)
+	meter.SetRoute("/api", 10*time.Millisecond)
+	meter.SetRoute("/api/users", 50*time.Millisecond)
+	st.Expect(t, meter.Threshold(info.Request), 50*time.Millisecond)
+	st.Expect(t, meter.Threshold(&http.Request{URL: &url.URL{Path: "/foo"}}), time.Second)
+
+	meter.Meter(info, metrics)
+	st.Expect(t, metrics.Snapshot().FloatGauges["res.apdex"], 0.5)
+}
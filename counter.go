@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueryWindow defines the maximum rolling window supported by rate queries.
+// Defaults to 5 minutes.
+var QueryWindow = 5 * time.Minute
+
+// Counter implements a monotonic counter metric.
+// Besides the count of the current publish cycle, counters keep track of the
+// total count and the recent rate, which can be queried via Meter.
+// Counters are reset in place on every publish cycle, so they can be safely retained.
+//
+// Counter is designed to be safety used by multiple goroutines.
+type Counter struct {
+	// count stores the current cycle count, accessed atomically.
+	count uint64
+	// total stores the total count across publish cycles, accessed atomically.
+	total uint64
+	// live stores the *liveCounter, lazily created on the first rate query.
+	live atomic.Value
+	// once guards the live counter creation.
+	once sync.Once
+	// stale is true if the counter was kept from a previous publish cycle
+	// and was not requested since then. Guarded by the Metrics lock.
+	stale bool
+}
+
+// Add increments the counter by one.
+func (c *Counter) Add() {
+	c.AddN(1)
+}
+
+// AddN increments the counter by the given delta.
+func (c *Counter) AddN(delta uint64) {
+	atomic.AddUint64(&c.count, delta)
+	atomic.AddUint64(&c.total, delta)
+	if live, ok := c.live.Load().(*liveCounter); ok {
+		live.add(time.Now(), delta)
+	}
+}
+
+// Count returns the counter value in the current publish cycle.
+func (c *Counter) Count() uint64 {
+	return atomic.LoadUint64(&c.count)
+}
+
+// reset resets the current cycle count, returning its previous value.
+func (c *Counter) reset() uint64 {
+	return atomic.SwapUint64(&c.count, 0)
+}
+
+// window returns the live counter tracking the recent counts,
+// creating it if necessary. Counts before the creation are not tracked.
+func (c *Counter) window() *liveCounter {
+	c.once.Do(func() {
+		c.live.Store(newLiveCounter())
+	})
+	return c.live.Load().(*liveCounter)
+}
+
+// liveCounter tracks the counts per second over the QueryWindow.
+type liveCounter struct {
+	sync.Mutex
+	ring    ring
+	buckets []uint64
+}
+
+// newLiveCounter creates a new live counter covering the QueryWindow.
+func newLiveCounter() *liveCounter {
+	size := int(QueryWindow / time.Second)
+	if size < 1 {
+		size = 1
+	}
+	return &liveCounter{ring: newRing(QueryWindow, size), buckets: make([]uint64, size)}
+}
+
+func (l *liveCounter) add(t time.Time, delta uint64) {
+	l.Lock()
+	defer l.Unlock()
+	l.buckets[l.ring.advance(t, l.clear)] += delta
+}
+
+// sum returns the count within the given window ending at the given time.
+func (l *liveCounter) sum(t time.Time, window time.Duration) uint64 {
+	l.Lock()
+	defer l.Unlock()
+	index := l.ring.advance(t, l.clear)
+	size := len(l.buckets)
+
+	buckets := int(window / l.ring.resolution)
+	if buckets > size {
+		buckets = size
+	}
+
+	var sum uint64
+	for x := 0; x < buckets; x++ {
+		sum += l.buckets[(index-x+size)%size]
+	}
+	return sum
+}
+
+func (l *liveCounter) clear(index int) {
+	l.buckets[index] = 0
+}
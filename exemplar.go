@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Buckets defines the upper bounds of the histogram buckets used to retain
+// the newest exemplar per bucket. Exporters representing histograms as
+// cumulative buckets use them as default bucket boundaries.
+// Defaults to 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000 and 10000.
+var Buckets = []int64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Exemplar represents a sample value recorded in an histogram, linked to a trace.
+type Exemplar struct {
+	// TraceID stores the trace identifier linked to the sample.
+	TraceID string
+	// Value stores the sample value.
+	Value int64
+	// Timestamp stores when the sample was recorded.
+	Timestamp time.Time
+}
+
+// TraceID returns the trace identifier of the given request, obtained from the
+// W3C traceparent header or, if not present, from the X-Request-ID header.
+func TraceID(r *http.Request) string {
+	// traceparent format: version-traceid-parentid-flags
+	if parts := strings.Split(r.Header.Get("traceparent"), "-"); len(parts) == 4 && len(parts[1]) == 32 {
+		return parts[1]
+	}
+	return r.Header.Get("X-Request-ID")
+}
+
+// exemplars retains the newest exemplar per bucket.
+// Caller is responsible of synchronization.
+type exemplars []*Exemplar
+
+// add retains the given exemplar, if newer than the current one in its bucket.
+func (e *exemplars) add(exemplar Exemplar) {
+	if *e == nil {
+		*e = make(exemplars, len(Buckets)+1)
+	}
+	index := sort.Search(len(Buckets), func(i int) bool { return Buckets[i] >= exemplar.Value })
+	if current := (*e)[index]; current == nil || !current.Timestamp.After(exemplar.Timestamp) {
+		(*e)[index] = &exemplar
+	}
+}
+
+// list returns the retained exemplars sorted by value.
+func (e exemplars) list() []Exemplar {
+	var list []Exemplar
+	for _, exemplar := range e {
+		if exemplar != nil {
+			list = append(list, *exemplar)
+		}
+	}
+	return list
+}
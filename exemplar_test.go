@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nbio/st"
+)
+
+func TestTraceID(t *testing.T) {
+	req := &http.Request{Header: make(http.Header)}
+	st.Expect(t, TraceID(req), "")
+
+	req.Header.Set("X-Request-ID", "foo")
+	st.Expect(t, TraceID(req), "foo")
+
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	st.Expect(t, TraceID(req), "4bf92f3577b34da6a3ce929d0e0e4736")
+}
+
+func TestHistogramExemplars(t *testing.T) {
+	hist := NewHistogram()
+	hist.RecordExemplar(3, "foo")
+	hist.RecordExemplar(4, "bar")
+	hist.RecordExemplar(300, "baz")
+	hist.RecordExemplar(20000, "")
+	st.Expect(t, hist.Count(), int64(4))
+
+	snapshot, _ := hist.Snapshot()
+	st.Expect(t, len(snapshot.Exemplars), 2)
+	st.Expect(t, snapshot.Exemplars[0].TraceID, "bar")
+	st.Expect(t, snapshot.Exemplars[0].Value, int64(4))
+	st.Expect(t, snapshot.Exemplars[1].TraceID, "baz")
+
+	other := NewHistogram()
+	other.RecordExemplar(10000, "qux")
+	otherSnapshot, _ := other.Snapshot()
+	merged, _ := MergeHistograms(snapshot, otherSnapshot)
+	st.Expect(t, len(merged.Exemplars), 3)
+	st.Expect(t, merged.Exemplars[2].TraceID, "qux")
+}
+
+func TestMeterResponseTimeExemplars(t *testing.T) {
+	info, metrics := createMetrics()
+	defer metrics.Reset()
+	info.Request.Header = make(http.Header)
+	info.Request.Header.Set("X-Request-ID", "foo")
+	info.TimeEnd = info.TimeStart.Add(150 * time.Millisecond)
+
+	MeterResponseTime(info, metrics)
+	exemplars := metrics.Snapshot().Histograms["res.time"].Exemplars
+	st.Expect(t, len(exemplars), 1)
+	st.Expect(t, exemplars[0].TraceID, "foo")
+	st.Expect(t, exemplars[0].Value, int64(150))
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import "sort"
+
+// SeriesTTL defines the number of publish cycles after which the series
+// not updated are expired, when metrics are kept across publish cycles.
+// Defaults to 10 publish cycles.
+var SeriesTTL = 10
+
+// Expire removes the series not updated within the last ttl collection cycles
+// and returns the expired series keys. Every call to Expire starts a new cycle.
+//
+// Expire is designed to be used instead of Reset when metrics are kept across publish cycles.
+// Derived metrics, such as histogram percentiles or rates, share the expired series key prefix.
+func (m *Metrics) Expire(ttl int) []string {
+	m.Lock()
+	defer m.Unlock()
+
+	var expired []string
+	for key, cycle := range m.touched {
+		if !m.expired(cycle, ttl) {
+			continue
+		}
+		m.remove(key)
+		expired = append(expired, key)
+	}
+
+	// Histograms are kept across cycles, so queries use the current ones
+	m.last = nil
+	m.cycle++
+	sort.Strings(expired)
+	return expired
+}
+
+// expired returns true if the series last updated in the given cycle
+// was not updated within the last ttl cycles, including the current one.
+// Caller must hold the lock.
+func (m *Metrics) expired(cycle, ttl int) bool {
+	return m.cycle-cycle >= ttl
+}
+
+// touch marks the given series key as updated in the current cycle.
+// Caller must hold the lock.
+func (m *Metrics) touch(key string) {
+	m.touched[key] = m.cycle
+}
+
+// remove removes all the metrics identified by the given series key.
+// Caller must hold the lock.
+func (m *Metrics) remove(key string) {
+	delete(m.counters, key)
+	if gauge, ok := m.gauges[key]; ok {
+		gauge.Remove()
+		delete(m.gauges, key)
+	}
+	delete(m.histograms, key)
+	delete(m.floatGauges, key)
+	delete(m.floatCounters, key)
+	delete(m.apdex, key)
+	if _, ok := m.timers[key]; ok {
+		delete(m.counters, key+TimerCountSuffix)
+		delete(m.timers, key)
+	}
+	delete(m.sets, key)
+	delete(m.topk, key)
+	delete(m.rates, key)
+	delete(m.windows, key)
+	delete(m.touched, key)
+
+	// Release the series from the cardinality limits
+	for alias, name := range m.keys {
+		if name == key {
+			delete(m.keys, alias)
+		}
+	}
+	if m.overflows[key] {
+		delete(m.overflows, key)
+		return
+	}
+	if family, _ := m.family(key); family != "" {
+		m.families[family]--
+	}
+	m.total--
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func TestMetricsExpire(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+	metrics.SetLimit("req.path", 1)
+
+	metrics.Counter("foo").Add()
+	metrics.Histogram("bar").RecordValue(10)
+	metrics.Counter("req.path./foo").Add()
+	st.Expect(t, len(metrics.Expire(2)), 0)
+
+	metrics.Counter("foo").Add()
+	st.Expect(t, len(metrics.Expire(2)), 0)
+
+	metrics.Counter("foo").Add()
+	st.Expect(t, metrics.Expire(2), []string{"bar", "req.path./foo"})
+
+	report := metrics.Snapshot()
+	st.Expect(t, report.Counters["foo"], uint64(3))
+	_, ok := report.Gauges["bar.P50"]
+	st.Expect(t, ok, false)
+	_, ok = report.Counters["req.path./foo"]
+	st.Expect(t, ok, false)
+
+	// Expired series release the cardinality limits
+	metrics.Counter("req.path./bar").Add()
+	st.Expect(t, metrics.Snapshot().Counters["req.path./bar"], uint64(1))
+}
+
+func TestMetricsExpireBoundary(t *testing.T) {
+	// Expire removes the series not updated within the last ttl cycles
+	metrics := NewMetrics()
+	defer metrics.Reset()
+	metrics.Counter("foo")
+	for x := 0; x < SeriesTTL; x++ {
+		st.Expect(t, len(metrics.Expire(SeriesTTL)), 0)
+	}
+	st.Expect(t, metrics.Expire(SeriesTTL), []string{"foo"})
+
+	// Reset removes the preserved series at the same cycle
+	metrics = NewMetrics()
+	defer metrics.Reset()
+	counter := metrics.Counter("foo")
+	for x := 0; x < SeriesTTL; x++ {
+		metrics.Reset()
+		st.Expect(t, metrics.counters["foo"], counter)
+	}
+	metrics.Reset()
+	_, ok := metrics.counters["foo"]
+	st.Expect(t, ok, false)
+}
+
+func TestMeterCumulative(t *testing.T) {
+	reports := make(chan Report, 1)
+	meter := &Meter{
+		metrics: NewMetrics(),
+		quit:    make(chan bool),
+		reporters: []Reporter{reporter(func(r Report) error {
+			reports <- r
+			return nil
+		})},
+	}
+	defer meter.metrics.Reset()
+	meter.SetCumulative(true)
+
+	meter.metrics.Counter("foo").Add()
+	for x := 0; x < SeriesTTL; x++ {
+		meter.Publish()
+		report := <-reports
+		st.Expect(t, report.Counters["foo"], uint64(1))
+	}
+
+	meter.Publish()
+	report := <-reports
+	st.Expect(t, report.Expired, []string{"foo"})
+	_, ok := report.Counters["foo"]
+	st.Expect(t, ok, false)
+}
+
+type reporter func(Report) error
+
+func (r reporter) Report(re Report) error {
+	return r(re)
+}
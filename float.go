@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// FloatGauge implements a float64 gauge metric.
+//
+// FloatGauge is designed to be safety used by multiple goroutines.
+type FloatGauge struct {
+	bits uint64
+}
+
+// Set sets the gauge value.
+func (g *FloatGauge) Set(value float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(value))
+}
+
+// Value returns the current gauge value.
+func (g *FloatGauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// FloatCounter implements a float64 counter metric.
+//
+// FloatCounter is designed to be safety used by multiple goroutines.
+type FloatCounter struct {
+	bits uint64
+}
+
+// Add increments the counter by the given delta.
+func (c *FloatCounter) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&c.bits)
+		value := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&c.bits, old, value) {
+			return
+		}
+	}
+}
+
+// Value returns the current counter value.
+func (c *FloatCounter) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&c.bits))
+}
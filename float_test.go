@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func TestFloatGauge(t *testing.T) {
+	gauge := &FloatGauge{}
+	st.Expect(t, gauge.Value(), float64(0))
+	gauge.Set(0.5)
+	st.Expect(t, gauge.Value(), 0.5)
+}
+
+func TestFloatCounter(t *testing.T) {
+	counter := &FloatCounter{}
+	wg := sync.WaitGroup{}
+	for x := 0; x < 10; x++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counter.Add(0.5)
+		}()
+	}
+	wg.Wait()
+	st.Expect(t, counter.Value(), float64(5))
+}
@@ -0,0 +1,11 @@
+module gopkg.in/vinxi/metrics.v0
+
+go 1.23
+
+require (
+	github.com/HdrHistogram/hdrhistogram-go v1.1.2
+	github.com/influxdata/influxdb1-client v0.0.0-20220302092344-a9ab5670611c
+	github.com/nbio/st v0.0.0-20140626010706-e9e8d9816f32
+	go.opentelemetry.io/proto/otlp v1.7.1
+	google.golang.org/protobuf v1.36.9
+)
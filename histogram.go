@@ -0,0 +1,248 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// HistogramMax defines the maximum value that can be recorded in histograms.
+// Defaults to 1e8.
+var HistogramMax int64 = 1e8
+
+// HistogramPrecision defines the number of significant value digits preserved by histograms.
+// Defaults to 5.
+var HistogramPrecision = 5
+
+// Percentiles stores the histogram percentiles reported as gauges, by key suffix.
+var Percentiles = map[string]float64{
+	"P50":  50,
+	"P75":  75,
+	"P90":  90,
+	"P95":  95,
+	"P99":  99,
+	"P999": 99.9,
+}
+
+// SplitPercentile splits the given histogram percentile gauge key, such as "res.time.P99",
+// into the histogram key and the percentile suffix. Returns false if the key is not a percentile.
+func SplitPercentile(key string) (string, string, bool) {
+	index := strings.LastIndex(key, ".")
+	if index == -1 {
+		return "", "", false
+	}
+	if _, ok := Percentiles[key[index+1:]]; !ok {
+		return "", "", false
+	}
+	return key[:index], key[index+1:], true
+}
+
+// ErrHistogramMerge is returned when merging an empty set of histogram snapshots.
+var ErrHistogramMerge = errors.New("metrics: no histogram snapshots to merge")
+
+// ErrHistogramData is returned when decoding malformed histogram data.
+var ErrHistogramData = errors.New("metrics: malformed histogram data")
+
+// Histogram implements an HDR histogram metric.
+//
+// Histogram is designed to be safety used by multiple goroutines.
+type Histogram struct {
+	sync.Mutex
+	hist      *hdrhistogram.Histogram
+	exemplars exemplars
+}
+
+// HistogramValue represents a recorded histogram value and its number of occurrences.
+type HistogramValue struct {
+	Value int64
+	Count int64
+}
+
+// HistogramSnapshot represents a serializable and mergeable histogram snapshot,
+// which carries the histogram summary and the full histogram buckets data.
+type HistogramSnapshot struct {
+	// Count stores the number of recorded values.
+	Count int64
+	// Min stores the minimum recorded value.
+	Min int64
+	// Max stores the maximum recorded value.
+	Max int64
+	// Mean stores the mean of the recorded values.
+	Mean float64
+	// Data stores the histogram encoded in the HdrHistogram V2 compressed base64 format.
+	Data string
+	// Exemplars stores the newest exemplar per bucket, sorted by value.
+	Exemplars []Exemplar
+}
+
+// NewHistogram creates a new histogram using the HistogramMax and HistogramPrecision settings.
+func NewHistogram() *Histogram {
+	return &Histogram{hist: hdrhistogram.New(1, HistogramMax, HistogramPrecision)}
+}
+
+// DecodeHistogram creates a new histogram from the given HdrHistogram V2 compressed base64 data.
+func DecodeHistogram(data string) (*Histogram, error) {
+	// Encoded header requires at least 8 bytes, encoded as 12 base64 characters
+	if len(data) < 12 {
+		return nil, ErrHistogramData
+	}
+	hist, err := hdrhistogram.Decode([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+	return &Histogram{hist: hist}, nil
+}
+
+// MergeHistograms merges the given histogram snapshots, typically collected from
+// multiple instances, into a single snapshot. Useful to calculate exact percentiles
+// across multiple instances.
+func MergeHistograms(snapshots ...HistogramSnapshot) (HistogramSnapshot, error) {
+	if len(snapshots) == 0 {
+		return HistogramSnapshot{}, ErrHistogramMerge
+	}
+
+	merged, err := DecodeHistogram(snapshots[0].Data)
+	if err != nil {
+		return HistogramSnapshot{}, err
+	}
+	for _, snapshot := range snapshots[1:] {
+		hist, err := DecodeHistogram(snapshot.Data)
+		if err != nil {
+			return HistogramSnapshot{}, err
+		}
+		merged.Merge(hist)
+	}
+	for _, snapshot := range snapshots {
+		for _, exemplar := range snapshot.Exemplars {
+			merged.exemplars.add(exemplar)
+		}
+	}
+
+	return merged.Snapshot()
+}
+
+// RecordValue records the given value.
+func (h *Histogram) RecordValue(v int64) error {
+	h.Lock()
+	defer h.Unlock()
+	return h.hist.RecordValue(v)
+}
+
+// RecordExemplar records the given value, retaining it as exemplar linked to the given trace ID.
+// Exemplars are only retained if the trace ID is not empty.
+func (h *Histogram) RecordExemplar(v int64, traceID string) error {
+	h.Lock()
+	defer h.Unlock()
+	if err := h.hist.RecordValue(v); err != nil {
+		return err
+	}
+	if traceID != "" {
+		h.exemplars.add(Exemplar{TraceID: traceID, Value: v, Timestamp: time.Now()})
+	}
+	return nil
+}
+
+// Merge merges the data of the given histogram into the histogram,
+// returning the number of values which could not be merged.
+func (h *Histogram) Merge(from *Histogram) int64 {
+	from.Lock()
+	hist := hdrhistogram.Import(from.hist.Export())
+	exemplars := from.exemplars.list()
+	from.Unlock()
+
+	h.Lock()
+	defer h.Unlock()
+	for _, exemplar := range exemplars {
+		h.exemplars.add(exemplar)
+	}
+	return h.hist.Merge(hist)
+}
+
+// ValueAtQuantile returns the recorded value at the given quantile, between 0 and 100.
+func (h *Histogram) ValueAtQuantile(q float64) int64 {
+	h.Lock()
+	defer h.Unlock()
+	return h.hist.ValueAtQuantile(q)
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() int64 {
+	h.Lock()
+	defer h.Unlock()
+	return h.hist.TotalCount()
+}
+
+// CumulativeCounts returns the number of recorded values less than
+// or equal to each of the given bucket upper bounds. Values are only counted
+// if all the values equivalent to them, within the histogram precision, are
+// less than or equal to the bound.
+func (h *Histogram) CumulativeCounts(bounds []int64) []int64 {
+	h.Lock()
+	defer h.Unlock()
+	counts := make([]int64, len(bounds))
+	for _, bar := range h.hist.Distribution() {
+		for x, bound := range bounds {
+			if bar.To <= bound {
+				counts[x] += bar.Count
+			}
+		}
+	}
+	return counts
+}
+
+// Values returns the distinct recorded values and its number of occurrences,
+// sorted by value. Values are rounded to the histogram precision.
+func (h *Histogram) Values() []HistogramValue {
+	h.Lock()
+	defer h.Unlock()
+	var values []HistogramValue
+	for _, bar := range h.hist.Distribution() {
+		if bar.Count > 0 {
+			values = append(values, HistogramValue{Value: bar.From, Count: bar.Count})
+		}
+	}
+	return values
+}
+
+// Snapshot returns a serializable snapshot of the histogram.
+func (h *Histogram) Snapshot() (HistogramSnapshot, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	data, err := h.hist.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	if err != nil {
+		return HistogramSnapshot{}, err
+	}
+
+	return HistogramSnapshot{
+		Count:     h.hist.TotalCount(),
+		Min:       h.hist.Min(),
+		Max:       h.hist.Max(),
+		Mean:      h.hist.Mean(),
+		Data:      string(data),
+		Exemplars: h.exemplars.list(),
+	}, nil
+}
+
+// percentiles returns the reported histogram percentiles by key suffix.
+func (h *Histogram) percentiles() map[string]int64 {
+	h.Lock()
+	defer h.Unlock()
+	values := make(map[string]int64, len(Percentiles))
+	for name, q := range Percentiles {
+		values[name] = h.hist.ValueAtQuantile(q)
+	}
+	return values
+}
+
+// ValueAtQuantile returns the recorded value at the given quantile, between 0 and 100.
+func (s HistogramSnapshot) ValueAtQuantile(q float64) (int64, error) {
+	hist, err := DecodeHistogram(s.Data)
+	if err != nil {
+		return 0, err
+	}
+	return hist.ValueAtQuantile(q), nil
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func TestHistogram(t *testing.T) {
+	hist := NewHistogram()
+	st.Expect(t, hist.RecordValue(0), nil)
+	for x := int64(1); x <= 100; x++ {
+		hist.RecordValue(x)
+	}
+	st.Expect(t, hist.Count(), int64(101))
+	st.Expect(t, hist.ValueAtQuantile(50), int64(50))
+	st.Expect(t, hist.ValueAtQuantile(100), int64(100))
+
+	snapshot, err := hist.Snapshot()
+	st.Expect(t, err, nil)
+	st.Expect(t, snapshot.Count, int64(101))
+	st.Expect(t, snapshot.Min, int64(0))
+	st.Expect(t, snapshot.Max, int64(100))
+	st.Expect(t, snapshot.Mean, float64(50))
+
+	decoded, err := DecodeHistogram(snapshot.Data)
+	st.Expect(t, err, nil)
+	st.Expect(t, decoded.Count(), int64(101))
+	st.Expect(t, decoded.ValueAtQuantile(99), int64(99))
+}
+
+func TestHistogramCumulativeCounts(t *testing.T) {
+	hist := NewHistogram()
+	for _, v := range []int64{1, 5, 6, 10, 100, 5000} {
+		hist.RecordValue(v)
+	}
+	st.Expect(t, hist.CumulativeCounts([]int64{5, 10, 1000}), []int64{2, 4, 5})
+
+	// Bounds falling inside an histogram bucket, such as [10000000, 10000063]
+	hist = NewHistogram()
+	hist.RecordValue(10000000)
+	hist.RecordValue(10000040)
+	hist.RecordValue(10000064)
+	st.Expect(t, hist.CumulativeCounts([]int64{10000001, 10000063, 10000127}), []int64{0, 2, 3})
+}
+
+func TestHistogramValues(t *testing.T) {
+	hist := NewHistogram()
+	for _, v := range []int64{10, 5, 10, 100} {
+		hist.RecordValue(v)
+	}
+	st.Expect(t, hist.Values(), []HistogramValue{{5, 1}, {10, 2}, {100, 1}})
+}
+
+func TestSplitPercentile(t *testing.T) {
+	name, perc, ok := SplitPercentile("res.time.P99")
+	st.Expect(t, ok, true)
+	st.Expect(t, name, "res.time")
+	st.Expect(t, perc, "P99")
+
+	_, _, ok = SplitPercentile("res.time.count")
+	st.Expect(t, ok, false)
+	_, _, ok = SplitPercentile("P99")
+	st.Expect(t, ok, false)
+}
+
+func TestMergeHistograms(t *testing.T) {
+	fast, slow := NewHistogram(), NewHistogram()
+	for x := 0; x < 99; x++ {
+		fast.RecordValue(10)
+	}
+	slow.RecordValue(1000)
+
+	a, _ := fast.Snapshot()
+	b, _ := slow.Snapshot()
+	merged, err := MergeHistograms(a, b)
+	st.Expect(t, err, nil)
+	st.Expect(t, merged.Count, int64(100))
+	st.Expect(t, merged.Max, int64(1000))
+
+	p99, err := merged.ValueAtQuantile(99)
+	st.Expect(t, err, nil)
+	st.Expect(t, p99, int64(10))
+	p100, _ := merged.ValueAtQuantile(100)
+	st.Expect(t, p100, int64(1000))
+
+	_, err = MergeHistograms()
+	st.Expect(t, err, ErrHistogramMerge)
+	_, err = MergeHistograms(HistogramSnapshot{Data: "foo"})
+	st.Expect(t, err, ErrHistogramData)
+	_, err = MergeHistograms(HistogramSnapshot{Data: "invalid histogram data"})
+	st.Reject(t, err, nil)
+}
+
+func TestMetricsHistogramSnapshot(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+
+	metrics.Histogram("foo").RecordValue(100)
+	report := metrics.Snapshot()
+	st.Expect(t, report.Histograms["foo"].Count, int64(1))
+	st.Expect(t, report.Histograms["foo"].Max, int64(100))
+	st.Expect(t, report.Gauges["foo.P999"], int64(100))
+}
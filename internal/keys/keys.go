@@ -0,0 +1,14 @@
+// Package keys provides helpers to iterate the metrics maps in a deterministic order.
+package keys
+
+import "sort"
+
+// Sorted returns the keys of the given map sorted in increasing order.
+func Sorted[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,12 @@
+package keys
+
+import (
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func TestSorted(t *testing.T) {
+	st.Expect(t, Sorted(map[string]int{"b": 1, "c": 2, "a": 3}), []string{"a", "b", "c"})
+	st.Expect(t, Sorted(map[string]string{}), []string{})
+}
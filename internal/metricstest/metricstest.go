@@ -0,0 +1,14 @@
+// Package metricstest provides the test fixtures shared by the metrics reporters.
+package metricstest
+
+import "gopkg.in/vinxi/metrics.v0"
+
+// Histogram returns the snapshot of a new histogram recording the given values.
+func Histogram(values ...int64) metrics.HistogramSnapshot {
+	hist := metrics.NewHistogram()
+	for _, value := range values {
+		hist.RecordValue(value)
+	}
+	snapshot, _ := hist.Snapshot()
+	return snapshot
+}
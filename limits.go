@@ -0,0 +1,88 @@
+package metrics
+
+import "strings"
+
+// MaxSeries defines the default maximum number of series allowed per Metrics registry.
+// New series exceeding the limit will be folded into the OverflowKey series.
+// Zero means no limit. Defaults to 10000.
+var MaxSeries = 10000
+
+// OverflowKey defines the series key used to aggregate the series exceeding the cardinality limits.
+// Series exceeding a metric family limit are folded into the <family>.__overflow__ series.
+const OverflowKey = "__overflow__"
+
+// DroppedSeriesKey defines the counter key used to count the series folded into overflow series.
+const DroppedSeriesKey = "metrics.series.dropped"
+
+// SetLimit sets the maximum number of series allowed for the given metric family.
+// A metric family is identified by a key prefix, e.g: "req.path" family matches the
+// "req.path./users" key. If multiple families match, the longest one will be used.
+// Zero means no limit.
+func (m *Metrics) SetLimit(family string, limit int) {
+	m.Lock()
+	m.limits[family] = limit
+	m.Unlock()
+}
+
+// SetMaxSeries sets the maximum number of series allowed in the registry.
+// Zero means no limit.
+func (m *Metrics) SetMaxSeries(limit int) {
+	m.Lock()
+	m.maxSeries = limit
+	m.Unlock()
+}
+
+// series returns the effective key to be used for the given series key,
+// folding new series into the overflow series if the cardinality limits are exceeded.
+// Caller must hold the lock.
+func (m *Metrics) series(key string) string {
+	name, ok := m.keys[key]
+	if !ok {
+		name = m.admit(key)
+		m.keys[key] = name
+	}
+	m.touch(name)
+	return name
+}
+
+// admit registers a new series, returning the overflow key if the limits are exceeded.
+// Caller must hold the lock.
+func (m *Metrics) admit(key string) string {
+	if m.overflows[key] {
+		return key
+	}
+
+	family, limit := m.family(key)
+	if family != "" && limit > 0 && m.families[family] >= limit {
+		m.counter(DroppedSeriesKey).Add()
+		return m.overflow(family + "." + OverflowKey)
+	}
+	if m.maxSeries > 0 && m.total >= m.maxSeries {
+		m.counter(DroppedSeriesKey).Add()
+		return m.overflow(OverflowKey)
+	}
+
+	if family != "" {
+		m.families[family]++
+	}
+	m.total++
+	return key
+}
+
+// overflow registers the given overflow series key generated by the limiter.
+// Caller must hold the lock.
+func (m *Metrics) overflow(key string) string {
+	m.overflows[key] = true
+	m.keys[key] = key
+	return key
+}
+
+// family returns the longest metric family and its limit matching the given key.
+func (m *Metrics) family(key string) (family string, limit int) {
+	for name, value := range m.limits {
+		if (key == name || strings.HasPrefix(key, name+".")) && len(name) > len(family) {
+			family, limit = name, value
+		}
+	}
+	return
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func TestMetricsFamilyLimit(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+	metrics.SetLimit("req.path", 2)
+
+	metrics.Counter("req.path./foo").Add()
+	metrics.Counter("req.path./bar").Add()
+	metrics.Counter("req.path./baz").Add()
+	metrics.Counter("req.path./qux").Add()
+	metrics.Counter("req.path./qux").Add()
+	metrics.Counter("req.total").Add()
+
+	report := metrics.Snapshot()
+	st.Expect(t, report.Counters["req.path./foo"], uint64(1))
+	st.Expect(t, report.Counters["req.path./bar"], uint64(1))
+	st.Expect(t, report.Counters["req.path.__overflow__"], uint64(3))
+	st.Expect(t, report.Counters["req.total"], uint64(1))
+	st.Expect(t, report.Counters[DroppedSeriesKey], uint64(2))
+	_, ok := report.Counters["req.path./baz"]
+	st.Expect(t, ok, false)
+
+	// Preserved counters are counted against the limits until expired
+	metrics.Reset()
+	metrics.Counter("req.path./baz").Add()
+	st.Expect(t, metrics.Snapshot().Counters["req.path.__overflow__"], uint64(1))
+
+	for x := 0; x <= SeriesTTL; x++ {
+		metrics.Reset()
+	}
+	metrics.Counter("req.path./baz").Add()
+	st.Expect(t, metrics.Snapshot().Counters["req.path./baz"], uint64(1))
+}
+
+func TestMetricsMaxSeries(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+	metrics.SetMaxSeries(1)
+
+	metrics.Histogram("foo").RecordValue(10)
+	metrics.Histogram("bar").RecordValue(20)
+	metrics.Histogram("baz").RecordValue(30)
+
+	report := metrics.Snapshot()
+	st.Expect(t, report.Gauges["foo.P50"], int64(10))
+	st.Expect(t, report.Gauges["__overflow__.P999"], int64(30))
+	st.Expect(t, report.Counters[DroppedSeriesKey], uint64(2))
+}
+
+func TestMetricsOverflowKeyLimit(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+	metrics.SetMaxSeries(1)
+
+	metrics.Counter("foo").Add()
+	metrics.Counter("bar.__overflow__").Add()
+	metrics.Counter("baz").Add()
+
+	report := metrics.Snapshot()
+	_, ok := report.Counters["bar.__overflow__"]
+	st.Expect(t, ok, false)
+	st.Expect(t, report.Counters[OverflowKey], uint64(2))
+	st.Expect(t, report.Counters[DroppedSeriesKey], uint64(2))
+}
+
+func TestMetricsPreservedSeriesLimit(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+	metrics.SetMaxSeries(1)
+
+	metrics.Rate("foo").Mark()
+	metrics.Reset()
+
+	// Preserved rates are counted against the limits
+	metrics.Rate("bar").Mark()
+	st.Expect(t, metrics.Snapshot().Counters[DroppedSeriesKey], uint64(1))
+
+	// Preserved rates are removed if not updated within SeriesTTL cycles
+	for x := 0; x <= SeriesTTL; x++ {
+		metrics.Reset()
+	}
+	metrics.Rate("bar").Mark()
+	report := metrics.Snapshot()
+	_, ok := report.FloatGauges["foo.m1"]
+	st.Expect(t, ok, false)
+	_, ok = report.FloatGauges["bar.m1"]
+	st.Expect(t, ok, true)
+}
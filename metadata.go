@@ -0,0 +1,51 @@
+package metrics
+
+// Kind represents the nature of a metric.
+type Kind string
+
+const (
+	// KindCounter represents a monotonic counter metric.
+	KindCounter Kind = "counter"
+	// KindGauge represents a gauge metric.
+	KindGauge Kind = "gauge"
+	// KindHistogram represents an histogram metric.
+	KindHistogram Kind = "histogram"
+	// KindTimer represents a timer metric.
+	KindTimer Kind = "timer"
+	// KindRate represents a rate metric.
+	KindRate Kind = "rate"
+	// KindTopK represents a heavy hitters metric.
+	KindTopK Kind = "topk"
+)
+
+// Metadata describes a metric, so reporters and exporters can emit proper descriptors.
+type Metadata struct {
+	// Kind stores the metric kind.
+	Kind Kind
+	// Unit stores the metric unit, such as "ms", "KB" or "requests".
+	Unit string
+	// Description stores a human friendly description of the metric.
+	Description string
+}
+
+// Descriptions stores the metadata of the metrics collected by the built-in meters.
+// You can register your custom metric metadata via Metrics.Describe().
+var Descriptions = map[string]Metadata{
+	"req.total":        {KindCounter, "requests", "Total number of served requests"},
+	"req.reads":        {KindCounter, "requests", "Total number of read requests"},
+	"req.writes":       {KindCounter, "requests", "Total number of write requests"},
+	"req.body.size":    {KindHistogram, "KB", "Request body size in KB"},
+	"req.clients":      {KindGauge, "clients", "Estimated number of unique clients"},
+	"req.top.paths":    {KindTopK, "requests", "Most requested URL paths"},
+	"req.top.clients":  {KindTopK, "requests", "Clients performing more requests"},
+	"res.top.errors":   {KindTopK, "responses", "URL paths replying with more error responses"},
+	"req.rate":         {KindRate, "requests/s", "Requests rate per second"},
+	"res.status.ok":    {KindCounter, "responses", "Total number of success responses"},
+	"res.status.bad":   {KindCounter, "responses", "Total number of bad responses"},
+	"res.status.error": {KindCounter, "responses", "Total number of error responses"},
+	"res.time":         {KindTimer, "ms", "Response time in milliseconds"},
+	"res.time.count":   {KindCounter, "", "Number of timed responses"},
+	"res.body.size":    {KindHistogram, "KB", "Response body size in KB"},
+	"res.apdex":        {KindGauge, "", "Apdex score"},
+	"res.error.rate":   {KindRate, "responses/s", "Error responses rate per second"},
+}
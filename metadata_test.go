@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func TestMetricsMetadata(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+
+	meta, ok := metrics.Metadata("req.total")
+	st.Expect(t, ok, true)
+	st.Expect(t, meta.Kind, KindCounter)
+
+	metrics.Describe("db.query", Metadata{KindTimer, "ms", "Database query time"})
+	metrics.Reset()
+
+	report := metrics.Snapshot()
+	st.Expect(t, report.Metadata["db.query"].Kind, KindTimer)
+	st.Expect(t, report.Metadata["db.query"].Unit, "ms")
+	st.Expect(t, report.Metadata["res.time"].Description, "Response time in milliseconds")
+}
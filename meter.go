@@ -26,11 +26,13 @@ type Reporter interface {
 // Supports configurable metrics reporters and meters.
 type Meter struct {
 	sync.Mutex
-	quit      chan bool
-	meters    []MeterFunc
-	reporters []Reporter
-	metrics   *Metrics
-	runtime   *RuntimeCollector
+	cumulative bool
+	quit       chan bool
+	meters     []MeterFunc
+	reporters  []Reporter
+	slos       []*SLO
+	metrics    *Metrics
+	runtime    *RuntimeCollector
 }
 
 // New creates a new metrics meter middleware.
@@ -73,6 +75,29 @@ func (m *Meter) AddReporter(reporters ...Reporter) {
 	m.Unlock()
 }
 
+// AddSLO adds one or multiple service level objectives to be tracked.
+func (m *Meter) AddSLO(slos ...*SLO) {
+	m.Lock()
+	m.slos = append(m.slos, slos...)
+	m.Unlock()
+}
+
+// Metrics returns the metrics store used by the meter.
+// Application handlers can use it to register custom metrics that will be
+// published in the same reports.
+func (m *Meter) Metrics() *Metrics {
+	return m.metrics
+}
+
+// SetCumulative sets whether the metrics should be kept across publish cycles
+// instead of being reset after every publish. In cumulative mode, the series
+// not updated within SeriesTTL publish cycles will be expired.
+func (m *Meter) SetCumulative(cumulative bool) {
+	m.Lock()
+	m.cumulative = cumulative
+	m.Unlock()
+}
+
 // Register registers the metrics middleware function.
 func (m *Meter) Register(mw layer.Middleware) {
 	mw.UsePriority("request", layer.TopHead, m.measureHTTP)
@@ -80,8 +105,27 @@ func (m *Meter) Register(mw layer.Middleware) {
 
 // Publish publishes the metrics snapshot report to the registered reporters.
 func (m *Meter) Publish() {
+	m.Lock()
+	slos := m.slos
+	cumulative := m.cumulative
+	m.Unlock()
+
+	var expired []string
+	if cumulative {
+		expired = m.metrics.Expire(SeriesTTL)
+	}
+
+	now := time.Now()
+	for _, slo := range slos {
+		slo.Publish(m.metrics, now)
+	}
+
 	report := m.metrics.Snapshot()
-	m.metrics.Reset()
+	report.Expired = expired
+	report.Cumulative = cumulative
+	if !cumulative {
+		m.metrics.Reset()
+	}
 
 	for _, reporter := range m.reporters {
 		go reporter.Report(report)
@@ -117,14 +161,18 @@ func (m *Meter) measureHTTP(h http.Handler) func(w http.ResponseWriter, r *http.
 	}
 }
 
-// gauge collects metrics and forward them to the registered meters
+// gauge collects metrics and forward them to the registered meters and SLOs.
 func (m *Meter) gauge(i *Info) {
 	for _, meter := range m.meters {
 		meter(i, m.metrics)
 	}
+	for _, slo := range m.slos {
+		slo.Record(i)
+	}
 }
 
-// gaugeRuntime collects runtime metrics and stores it in a histogram.
+// gaugeRuntime collects runtime metrics and stores it in a gauge.
 func (m *Meter) gaugeRuntime(key string, val uint64) {
-	m.metrics.Histogram(key).RecordValue(int64(val))
+	m.metrics.Describe(key, Metadata{Kind: KindGauge, Description: "Go runtime statistic"})
+	m.metrics.Guage(key).Set(int64(val))
 }
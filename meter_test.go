@@ -34,6 +34,20 @@ func TestMeter(t *testing.T) {
 	metrics.measureHTTP(handler)(rw, req)
 }
 
+func TestMeterRuntime(t *testing.T) {
+	meter := &Meter{metrics: NewMetrics(), quit: make(chan bool)}
+	defer meter.metrics.Reset()
+
+	meter.gaugeRuntime("runtime.goroutines", 5)
+	meter.gaugeRuntime("runtime.goroutines", 3)
+
+	report := meter.metrics.Snapshot()
+	st.Expect(t, report.Gauges["runtime.goroutines"], int64(3))
+	st.Expect(t, report.Metadata["runtime.goroutines"].Kind, KindGauge)
+	_, ok := report.Histograms["runtime.goroutines"]
+	st.Expect(t, ok, false)
+}
+
 type writerStub struct {
 	code int
 	data string
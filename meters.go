@@ -2,7 +2,9 @@ package metrics
 
 import (
 	"math"
-	"time"
+	"net"
+	"net/http"
+	"strings"
 )
 
 // Meters stores the built-in function meters used by default for metrics collection.
@@ -14,20 +16,30 @@ var Meters = []MeterFunc{
 	MeterResponseTime,
 	MeterResponseBodySize,
 	MeterRequestBodySize,
+	MeterApdex,
+	MeterUniqueClients,
 }
 
 // MeterNumberOfRequests is used to register the total number of served requests.
+// If PublishRates is enabled, the request rates will be also measured.
 func MeterNumberOfRequests(i *Info, m *Metrics) {
 	m.Counter("req.total").Add()
+	if PublishRates {
+		m.Rate("req.rate").Mark()
+	}
 }
 
 // MeterResponseStatus is used to count the response status code by range (2xx, 4xx, 5xx).
+// If PublishRates is enabled, the error rates will be also measured.
 func MeterResponseStatus(i *Info, m *Metrics) {
 	s := i.Status / 100
 	if s >= 2 && s < 4 {
 		m.Counter("res.status.ok").Add()
 	} else if s == 5 {
 		m.Counter("res.status.error").Add()
+		if PublishRates {
+			m.Rate("res.error.rate").Mark()
+		}
 	} else if s == 4 {
 		m.Counter("res.status.bad").Add()
 	}
@@ -51,10 +63,9 @@ func MeterRequestOperation(i *Info, m *Metrics) {
 }
 
 // MeterResponseTime is used to measure the HTTP request/response time.
-// Data will be stored in a histogram.
+// Data will be stored in a timer, linking exemplars to the request trace ID, if present.
 func MeterResponseTime(i *Info, m *Metrics) {
-	resTime := i.TimeEnd.Sub(i.TimeStart).Nanoseconds() / int64(time.Millisecond)
-	m.Histogram("res.time").RecordValue(resTime)
+	m.Timer("res.time").RecordExemplar(i.TimeEnd.Sub(i.TimeStart), TraceID(i.Request))
 }
 
 // MeterResponseBodySize is used to measure the HTTP response body length.
@@ -73,6 +84,51 @@ func MeterRequestBodySize(i *Info, m *Metrics) {
 	}
 }
 
+// MeterUniqueClients is used to estimate the number of unique clients.
+// Clients are identified by the X-Forwarded-For header, if present, or the remote address.
+// Data will be stored in a distinct count set.
+func MeterUniqueClients(i *Info, m *Metrics) {
+	if ip := clientIP(i.Request); ip != "" {
+		m.Set("req.clients").Add(ip)
+	}
+}
+
+// MeterTopPaths is used to track the most requested URL paths.
+// Data will be stored in a top-k metric.
+func MeterTopPaths(i *Info, m *Metrics) {
+	if i.Request.URL != nil {
+		m.TopK("req.top.paths", TopKSize).Add(i.Request.URL.Path)
+	}
+}
+
+// MeterTopClients is used to track the clients performing more requests.
+// Data will be stored in a top-k metric.
+func MeterTopClients(i *Info, m *Metrics) {
+	if ip := clientIP(i.Request); ip != "" {
+		m.TopK("req.top.clients", TopKSize).Add(ip)
+	}
+}
+
+// MeterTopErrors is used to track the URL paths replying with more error responses.
+// Data will be stored in a top-k metric.
+func MeterTopErrors(i *Info, m *Metrics) {
+	if i.Status >= 500 && i.Request.URL != nil {
+		m.TopK("res.top.errors", TopKSize).Add(i.Request.URL.Path)
+	}
+}
+
+// clientIP returns the originating client IP of the given request.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // toKB converts n bytes into KB.
 func toKB(n int64) int64 {
 	return int64(math.Floor((float64(n) / 1024) + 0.5))
@@ -30,6 +30,37 @@ type Report struct {
 	Gauges map[string]int64
 	// Counters stores the metrics counters accesible by key.
 	Counters map[string]uint64
+	// FloatGauges stores the metrics float gauges values accesible by key.
+	FloatGauges map[string]float64
+	// FloatCounters stores the metrics float counters accesible by key.
+	FloatCounters map[string]float64
+	// Metadata stores the registered metrics metadata accesible by key.
+	Metadata map[string]Metadata
+	// Histograms stores the metrics histograms snapshots accesible by key.
+	// Histogram percentiles are also exposed as gauges, e.g: <key>.P99.
+	Histograms map[string]HistogramSnapshot
+	// TopK stores the metrics heavy hitters accesible by key.
+	TopK map[string][]TopKEntry
+	// Expired stores the keys of the series expired since the previous report,
+	// so reporters can stop exposing them.
+	Expired []string
+	// Cumulative stores whether the metrics are kept across publish cycles.
+	// Otherwise, counters and histograms only store the values of the last publish cycle.
+	Cumulative bool
+	// Start stores when the reported publish cycle started. In cumulative mode,
+	// metrics are kept across publish cycles, so it stores when the collection started.
+	Start time.Time
+}
+
+// IsHistogramPercentile returns true if the given gauge key is the percentile
+// of a reported histogram, such as "res.time.P99".
+func (r Report) IsHistogramPercentile(key string) bool {
+	name, _, ok := SplitPercentile(key)
+	if !ok {
+		return false
+	}
+	_, ok = r.Histograms[name]
+	return ok
 }
 
 // Metrics is used to temporary store metrics data of multiple origins and nature.
@@ -42,29 +73,77 @@ type Metrics struct {
 	// gauges stores gauges
 	gauges map[string]metrics.Gauge
 	// counters stores counters by key
-	counters map[string]metrics.Counter
+	counters map[string]*Counter
 	// histograms stores histograms by key.
-	histograms map[string]*metrics.Histogram
+	histograms map[string]*Histogram
+	// last stores the histograms of the last completed publish cycle by key.
+	last map[string]*Histogram
+	// floatGauges stores float gauges by key.
+	floatGauges map[string]*FloatGauge
+	// floatCounters stores float counters by key.
+	floatCounters map[string]*FloatCounter
+	// apdex stores Apdex scores by key.
+	apdex map[string]*Apdex
+	// timers stores timers by key.
+	timers map[string]*Timer
+	// sets stores distinct count sets by key.
+	sets map[string]*Set
+	// topk stores heavy hitters by key.
+	topk map[string]*TopK
+	// rates stores rates by key, preserved across resets.
+	rates map[string]*Rate
+	// windows stores window histograms by key, preserved across resets.
+	windows map[string]*WindowHistogram
+	// gaugeFuncs stores the callback gauges by key, preserved across resets.
+	gaugeFuncs map[string]func() int64
+	// gaugeFloatFuncs stores the float callback gauges by key, preserved across resets.
+	gaugeFloatFuncs map[string]func() float64
+	// metadata stores the metrics metadata by key, preserved across resets.
+	metadata map[string]Metadata
+	// limits stores the series limits by metric family, preserved across resets.
+	limits map[string]int
+	// maxSeries stores the maximum number of series.
+	maxSeries int
+	// keys stores the effective key of each known series key.
+	keys map[string]string
+	// overflows stores the overflow series keys generated by the cardinality limits.
+	overflows map[string]bool
+	// families stores the number of series by metric family.
+	families map[string]int
+	// total stores the total number of series.
+	total int
+	// touched stores the latest cycle when each series was updated.
+	touched map[string]int
+	// cycle stores the current collection cycle.
+	cycle int
+	// start stores when the current collection cycle started.
+	start time.Time
 }
 
 // NewMetrics creates a new metrics object for reporting.
 func NewMetrics() *Metrics {
-	m := &Metrics{}
+	m := &Metrics{
+		rates:           make(map[string]*Rate),
+		windows:         make(map[string]*WindowHistogram),
+		gaugeFuncs:      make(map[string]func() int64),
+		gaugeFloatFuncs: make(map[string]func() float64),
+		metadata:        make(map[string]Metadata),
+		limits:          make(map[string]int),
+		maxSeries:       MaxSeries,
+	}
+	for key, meta := range Descriptions {
+		m.metadata[key] = meta
+	}
 	m.Reset()
 	return m
 }
 
 // Counter returns a counter metric by key.
 // If the counter doesn't exists, it will be transparently created.
-func (m *Metrics) Counter(key string) metrics.Counter {
+func (m *Metrics) Counter(key string) *Counter {
 	m.Lock()
 	defer m.Unlock()
-	counter, ok := m.counters[key]
-	if !ok {
-		counter = metrics.Counter(key)
-		m.counters[key] = counter
-	}
-	return counter
+	return m.counter(m.series(key))
 }
 
 // Guage returns a gauge metric by key.
@@ -72,6 +151,7 @@ func (m *Metrics) Counter(key string) metrics.Counter {
 func (m *Metrics) Guage(key string) metrics.Gauge {
 	m.Lock()
 	defer m.Unlock()
+	key = m.series(key)
 	gauge, ok := m.gauges[key]
 	if !ok {
 		gauge = metrics.Gauge(key)
@@ -80,33 +160,379 @@ func (m *Metrics) Guage(key string) metrics.Gauge {
 	return gauge
 }
 
+// FloatGauge returns a float gauge metric by key.
+// If the gauge doesn't exists, it will be transparently created.
+func (m *Metrics) FloatGauge(key string) *FloatGauge {
+	m.Lock()
+	defer m.Unlock()
+	key = m.series(key)
+	gauge, ok := m.floatGauges[key]
+	if !ok {
+		gauge = &FloatGauge{}
+		m.floatGauges[key] = gauge
+	}
+	return gauge
+}
+
+// FloatCounter returns a float counter metric by key.
+// If the counter doesn't exists, it will be transparently created.
+func (m *Metrics) FloatCounter(key string) *FloatCounter {
+	m.Lock()
+	defer m.Unlock()
+	key = m.series(key)
+	counter, ok := m.floatCounters[key]
+	if !ok {
+		counter = &FloatCounter{}
+		m.floatCounters[key] = counter
+	}
+	return counter
+}
+
+// GaugeFunc registers a callback gauge by key, which will be lazily evaluated
+// every time a snapshot is collected. Useful to report values such as
+// connection pool size, queue depth or cache entries.
+// Callback gauges are preserved across resets until removed via RemoveGaugeFunc().
+func (m *Metrics) GaugeFunc(key string, fn func() int64) {
+	m.Lock()
+	m.gaugeFuncs[key] = fn
+	m.Unlock()
+}
+
+// GaugeFloatFunc registers a float callback gauge by key, which will be lazily evaluated
+// every time a snapshot is collected.
+// Callback gauges are preserved across resets until removed via RemoveGaugeFunc().
+func (m *Metrics) GaugeFloatFunc(key string, fn func() float64) {
+	m.Lock()
+	m.gaugeFloatFuncs[key] = fn
+	m.Unlock()
+}
+
+// RemoveGaugeFunc unregisters a callback gauge by key.
+func (m *Metrics) RemoveGaugeFunc(key string) {
+	m.Lock()
+	delete(m.gaugeFuncs, key)
+	delete(m.gaugeFloatFuncs, key)
+	m.Unlock()
+}
+
 // Histogram returns an histrogram by key.
 // If the histogram doesn't exists, it will be transparently created.
-func (m *Metrics) Histogram(key string) *metrics.Histogram {
+func (m *Metrics) Histogram(key string) *Histogram {
 	m.Lock()
 	defer m.Unlock()
-	hist, ok := m.histograms[key]
+	key = m.series(key)
+	return m.histogram(key)
+}
+
+// WindowHistogram returns a rolling time window histogram by key.
+// If the histogram doesn't exists, it will be transparently created with the given
+// window and granularity. Window histograms are reported as histograms, and they
+// can be queried at any moment without waiting for a publish cycle.
+func (m *Metrics) WindowHistogram(key string, window, granularity time.Duration) *WindowHistogram {
+	m.Lock()
+	defer m.Unlock()
+	key = m.series(key)
+	hist, ok := m.windows[key]
 	if !ok {
-		hist = metrics.NewHistogram(key, 0, 1e8, 5)
-		m.histograms[key] = hist
+		hist = NewWindowHistogram(window, granularity)
+		m.windows[key] = hist
 	}
 	return hist
 }
 
+// Timer returns a timer by key.
+// If the timer doesn't exists, it will be transparently created.
+// Timers are reported as an histogram identified by the timer key,
+// a <key>.count counter and a <key>.rate float gauge.
+// Since metrics are reset on every publish cycle, you should not retain the returned timer.
+func (m *Metrics) Timer(key string) *Timer {
+	m.Lock()
+	defer m.Unlock()
+	key = m.series(key)
+	timer, ok := m.timers[key]
+	if !ok {
+		timer = &Timer{counter: m.counter(key + TimerCountSuffix), histogram: m.histogram(key)}
+		m.timers[key] = timer
+	}
+	return timer
+}
+
+// Apdex returns an Apdex score metric by key.
+// If the Apdex doesn't exists, it will be transparently created.
+func (m *Metrics) Apdex(key string) *Apdex {
+	m.Lock()
+	defer m.Unlock()
+	key = m.series(key)
+	apdex, ok := m.apdex[key]
+	if !ok {
+		apdex = &Apdex{}
+		m.apdex[key] = apdex
+	}
+	return apdex
+}
+
+// Set returns a distinct count set by key.
+// If the set doesn't exists, it will be transparently created with SetPrecision.
+// Sets are reported as gauges storing the estimated number of unique values.
+func (m *Metrics) Set(key string) *Set {
+	m.Lock()
+	defer m.Unlock()
+	key = m.series(key)
+	set, ok := m.sets[key]
+	if !ok {
+		set = NewSet(SetPrecision)
+		m.sets[key] = set
+	}
+	return set
+}
+
+// TopK returns a heavy hitters metric by key, tracking the top k items.
+// If the metric doesn't exists, it will be transparently created.
+func (m *Metrics) TopK(key string, k int) *TopK {
+	m.Lock()
+	defer m.Unlock()
+	key = m.series(key)
+	topk, ok := m.topk[key]
+	if !ok {
+		topk = NewTopK(k)
+		m.topk[key] = topk
+	}
+	return topk
+}
+
+// Rate returns a rate by key.
+// If the rate doesn't exists, it will be transparently created.
+// Rates are reported as float gauges with the following keys, expressed in events per second:
+//
+//	<key>.m1, <key>.m5, <key>.m15, <key>.mean
+func (m *Metrics) Rate(key string) *Rate {
+	m.Lock()
+	defer m.Unlock()
+	key = m.series(key)
+	rate, ok := m.rates[key]
+	if !ok {
+		rate = NewRate()
+		m.rates[key] = rate
+	}
+	return rate
+}
+
+// Describe registers the metadata of the metric identified by the given key.
+// Metadata is preserved across resets and exposed in the reports.
+func (m *Metrics) Describe(key string, meta Metadata) {
+	m.Lock()
+	m.metadata[key] = meta
+	m.Unlock()
+}
+
+// Metadata returns the registered metadata of the metric identified by the given key.
+func (m *Metrics) Metadata(key string) (Metadata, bool) {
+	m.Lock()
+	defer m.Unlock()
+	meta, ok := m.metadata[key]
+	return meta, ok
+}
+
 // Snapshot collects and returns a report of the existent counters and gauges metrics
 // to be consumed by metrics publishers and listeners.
+//
+// Apdex scores, timer rates and rates are reported as float gauges.
 func (m *Metrics) Snapshot() Report {
-	c, g := metrics.Snapshot()
-	return Report{Gauges: g, Counters: c}
+	_, g := metrics.Snapshot()
+	c := make(map[string]uint64)
+	fc := make(map[string]float64)
+	fg := make(map[string]float64)
+	meta := make(map[string]Metadata)
+	topk := make(map[string][]TopKEntry)
+	hists := make(map[string]HistogramSnapshot)
+
+	m.Lock()
+	for key, counter := range m.counters {
+		count := counter.Count()
+		if counter.stale && count == 0 {
+			continue
+		}
+		c[key] = count
+	}
+	for key, value := range m.metadata {
+		meta[key] = value
+	}
+	for key, counter := range m.floatCounters {
+		fc[key] = counter.Value()
+	}
+	for key, gauge := range m.floatGauges {
+		fg[key] = gauge.Value()
+	}
+	for key, apdex := range m.apdex {
+		fg[key] = apdex.Score()
+	}
+	for key, set := range m.sets {
+		g[key] = int64(set.Count())
+	}
+	for key, value := range m.topk {
+		topk[key] = value.Top()
+	}
+	histograms := make(map[string]*Histogram, len(m.histograms)+len(m.windows))
+	for key, hist := range m.histograms {
+		histograms[key] = hist
+	}
+	for key, window := range m.windows {
+		histograms[key] = window.Histogram()
+	}
+	for key, hist := range histograms {
+		for name, value := range hist.percentiles() {
+			g[key+"."+name] = value
+		}
+		if snapshot, err := hist.Snapshot(); err == nil {
+			hists[key] = snapshot
+		}
+	}
+	start := m.start
+	elapsed := time.Since(start).Seconds()
+	for key, timer := range m.timers {
+		fg[key+".rate"] = float64(timer.Count()) / elapsed
+	}
+	for key, rate := range m.rates {
+		fg[key+".m1"] = rate.Rate1()
+		fg[key+".m5"] = rate.Rate5()
+		fg[key+".m15"] = rate.Rate15()
+		fg[key+".mean"] = rate.RateMean()
+	}
+	gaugeFuncs := make(map[string]func() int64, len(m.gaugeFuncs))
+	for key, fn := range m.gaugeFuncs {
+		gaugeFuncs[key] = fn
+	}
+	gaugeFloatFuncs := make(map[string]func() float64, len(m.gaugeFloatFuncs))
+	for key, fn := range m.gaugeFloatFuncs {
+		gaugeFloatFuncs[key] = fn
+	}
+	m.Unlock()
+
+	// Evaluate callback gauges without holding the lock
+	for key, fn := range gaugeFuncs {
+		g[key] = fn()
+	}
+	for key, fn := range gaugeFloatFuncs {
+		fg[key] = fn()
+	}
+
+	return Report{
+		Gauges:        g,
+		Counters:      c,
+		FloatGauges:   fg,
+		FloatCounters: fc,
+		Metadata:      meta,
+		TopK:          topk,
+		Histograms:    hists,
+		Start:         start,
+	}
 }
 
-// Reset resets all the metrics (counters, gauges & histograms) to zero.
+// Reset resets all the metrics (counters, gauges, histograms, timers, sets, top-k & apdex) to zero.
 // You should collect them first with Snapshot(), otherwise the collected data will be lost.
+// Rates, window histograms, callback gauges and metadata are preserved across publish cycles.
+// Counters are reset in place, so the counters retained by the callers remain valid.
+//
+// Preserved counters, rates and window histograms are counted against the cardinality limits,
+// and they are removed if not updated within SeriesTTL publish cycles.
 func (m *Metrics) Reset() {
 	metrics.Reset()
 	m.Lock()
 	m.gauges = make(map[string]metrics.Gauge)
-	m.counters = make(map[string]metrics.Counter)
-	m.histograms = make(map[string]*metrics.Histogram)
+	m.resetCounters()
+	m.last = m.histograms
+	m.histograms = make(map[string]*Histogram)
+	m.floatGauges = make(map[string]*FloatGauge)
+	m.floatCounters = make(map[string]*FloatCounter)
+	m.apdex = make(map[string]*Apdex)
+	m.timers = make(map[string]*Timer)
+	m.sets = make(map[string]*Set)
+	m.topk = make(map[string]*TopK)
+	m.resetSeries()
+	m.start = time.Now()
 	m.Unlock()
 }
+
+// resetCounters resets the counters in place, keeping the counters updated
+// in the last cycle and the counters series, which are expired by resetSeries.
+// Caller must hold the lock.
+func (m *Metrics) resetCounters() {
+	if m.counters == nil {
+		m.counters = make(map[string]*Counter)
+	}
+	for key, counter := range m.counters {
+		counter.stale = true
+		updated := counter.reset() > 0
+		if _, ok := m.touched[key]; ok {
+			if updated {
+				m.touch(key)
+			}
+			continue
+		}
+		if !updated {
+			delete(m.counters, key)
+		}
+	}
+}
+
+// resetSeries starts a new collection cycle, resetting the cardinality limits
+// and readmitting the series preserved across cycles.
+// Caller must hold the lock.
+func (m *Metrics) resetSeries() {
+	touched, overflows := m.touched, m.overflows
+	m.keys = make(map[string]string)
+	m.overflows = make(map[string]bool)
+	m.families = make(map[string]int)
+	m.total = 0
+	m.touched = make(map[string]int)
+
+	for key, cycle := range touched {
+		_, isRate := m.rates[key]
+		_, isWindow := m.windows[key]
+		_, isCounter := m.counters[key]
+		if !isRate && !isWindow && !isCounter {
+			continue
+		}
+		if m.expired(cycle, SeriesTTL) {
+			delete(m.rates, key)
+			delete(m.windows, key)
+			delete(m.counters, key)
+			continue
+		}
+
+		m.touched[key] = cycle
+		if overflows[key] {
+			m.overflow(key)
+			continue
+		}
+		m.keys[key] = key
+		if family, _ := m.family(key); family != "" {
+			m.families[family]++
+		}
+		m.total++
+	}
+	m.cycle++
+}
+
+// counter returns a counter by key, creating it if necessary.
+// Caller must hold the lock.
+func (m *Metrics) counter(key string) *Counter {
+	counter, ok := m.counters[key]
+	if !ok {
+		counter = &Counter{}
+		m.counters[key] = counter
+	}
+	counter.stale = false
+	return counter
+}
+
+// histogram returns an histogram by key, creating it if necessary.
+// Caller must hold the lock.
+func (m *Metrics) histogram(key string) *Histogram {
+	hist, ok := m.histograms[key]
+	if !ok {
+		hist = NewHistogram()
+		m.histograms[key] = hist
+	}
+	return hist
+}
@@ -12,6 +12,7 @@ func TestMetrics(t *testing.T) {
 
 	metrics.Counter("foo").Add()
 	st.Expect(t, metrics.Snapshot().Counters["foo"], uint64(1))
+	st.Expect(t, metrics.Snapshot().Start, metrics.start)
 
 	metrics.Guage("foo").Set(1)
 	st.Expect(t, metrics.Snapshot().Gauges["foo"], int64(1))
@@ -24,3 +25,68 @@ func TestMetrics(t *testing.T) {
 	st.Expect(t, metrics.Snapshot().Gauges["foo.P99"], int64(100))
 	st.Expect(t, metrics.Snapshot().Gauges["foo.P999"], int64(100))
 }
+
+func TestMetricsCounterReset(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+
+	counter := metrics.Counter("foo")
+	counter.Add()
+	metrics.Reset()
+	st.Expect(t, len(metrics.Snapshot().Counters), 0)
+
+	// Retained counters remain valid across publish cycles
+	counter.AddN(2)
+	st.Expect(t, metrics.Snapshot().Counters["foo"], uint64(2))
+	st.Expect(t, metrics.Counter("foo"), counter)
+
+	// Idle counters are removed after SeriesTTL publish cycles
+	for x := 0; x <= SeriesTTL+1; x++ {
+		metrics.Reset()
+	}
+	st.Expect(t, metrics.Counter("foo") == counter, false)
+}
+
+func TestReportIsHistogramPercentile(t *testing.T) {
+	report := Report{Histograms: map[string]HistogramSnapshot{"res.time": {}}}
+	st.Expect(t, report.IsHistogramPercentile("res.time.P99"), true)
+	st.Expect(t, report.IsHistogramPercentile("req.size.P99"), false)
+	st.Expect(t, report.IsHistogramPercentile("res.time"), false)
+}
+
+func TestMetricsFloat(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+
+	metrics.FloatCounter("foo").Add(0.5)
+	metrics.FloatCounter("foo").Add(1)
+	st.Expect(t, metrics.Snapshot().FloatCounters["foo"], 1.5)
+
+	metrics.FloatGauge("foo").Set(0.25)
+	st.Expect(t, metrics.Snapshot().FloatGauges["foo"], 0.25)
+
+	metrics.Reset()
+	st.Expect(t, len(metrics.Snapshot().FloatCounters), 0)
+}
+
+func TestMetricsGaugeFunc(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+
+	size := int64(10)
+	metrics.GaugeFunc("pool.size", func() int64 { return size })
+	metrics.GaugeFloatFunc("cache.ratio", func() float64 { return 0.25 })
+	st.Expect(t, metrics.Snapshot().Gauges["pool.size"], int64(10))
+	st.Expect(t, metrics.Snapshot().FloatGauges["cache.ratio"], 0.25)
+
+	size = 20
+	metrics.Reset()
+	st.Expect(t, metrics.Snapshot().Gauges["pool.size"], int64(20))
+
+	metrics.RemoveGaugeFunc("pool.size")
+	metrics.RemoveGaugeFunc("cache.ratio")
+	_, ok := metrics.Snapshot().Gauges["pool.size"]
+	st.Expect(t, ok, false)
+	_, ok = metrics.Snapshot().FloatGauges["cache.ratio"]
+	st.Expect(t, ok, false)
+}
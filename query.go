@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Counter returns the total count of the given counter since it was created,
+// regardless of the publish cycles. Returns zero if the counter doesn't exist.
+// Counters not updated within SeriesTTL publish cycles are removed,
+// so their total restarts from zero if they are updated again.
+//
+// Query methods are read-only and don't disturb the publish cycle, so they can be
+// safely used by other middleware, such as load shedders or circuit breakers.
+func (m *Meter) Counter(key string) uint64 {
+	counter := m.metrics.find(key)
+	if counter == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&counter.total)
+}
+
+// Rate returns the per second rate of the given counter over the given window ending now.
+// Window is limited to QueryWindow. Returns zero if the counter doesn't exist.
+//
+// Rates are lazily tracked from the first query of every counter onwards,
+// so the first queries may underestimate the rate.
+func (m *Meter) Rate(key string, window time.Duration) float64 {
+	if window > QueryWindow {
+		window = QueryWindow
+	}
+	counter := m.metrics.find(key)
+	if counter == nil || window < time.Second {
+		return 0
+	}
+	return float64(counter.window().sum(time.Now(), window)) / window.Seconds()
+}
+
+// Quantile returns the value at the given quantile, between 0 and 100, of the given
+// window histogram, or the histogram or timer recorded in the last completed publish cycle.
+// Before the first publish cycle completes, or in cumulative mode, where histograms are kept
+// across publish cycles, the current histogram is used instead.
+// Returns zero if the histogram doesn't exist.
+func (m *Meter) Quantile(key string, q float64) int64 {
+	m.metrics.Lock()
+	key = m.metrics.lookup(key)
+	window, isWindow := m.metrics.windows[key]
+	hist, isHist := m.metrics.last[key]
+	if m.metrics.last == nil {
+		hist, isHist = m.metrics.histograms[key]
+	}
+	m.metrics.Unlock()
+
+	switch {
+	case isWindow:
+		return window.ValueAtQuantile(q)
+	case isHist:
+		return hist.ValueAtQuantile(q)
+	}
+	return 0
+}
+
+// find returns the counter by key, if exists.
+func (m *Metrics) find(key string) *Counter {
+	m.Lock()
+	defer m.Unlock()
+	return m.counters[m.lookup(key)]
+}
+
+// lookup returns the effective series key for the given key, without registering it.
+// Caller must hold the lock.
+func (m *Metrics) lookup(key string) string {
+	if name, ok := m.keys[key]; ok {
+		return name
+	}
+	return key
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbio/st"
+)
+
+func TestMeterQuery(t *testing.T) {
+	meter := &Meter{metrics: NewMetrics(), quit: make(chan bool)}
+	defer meter.metrics.Reset()
+
+	// Rates are tracked from the first query onwards
+	counter := meter.metrics.Counter("foo")
+	st.Expect(t, meter.Rate("foo", time.Minute), float64(0))
+	counter.AddN(60)
+	meter.metrics.Histogram("bar").RecordValue(100)
+	meter.metrics.WindowHistogram("baz", time.Minute, time.Second).RecordValue(200)
+
+	st.Expect(t, meter.Counter("foo"), uint64(60))
+	st.Expect(t, meter.Rate("foo", time.Minute), float64(1))
+	st.Expect(t, meter.Quantile("bar", 99), int64(100))
+	st.Expect(t, meter.Quantile("baz", 99), int64(200))
+
+	// Missing series
+	st.Expect(t, meter.Counter("missing"), uint64(0))
+	st.Expect(t, meter.Rate("missing", time.Minute), float64(0))
+	st.Expect(t, meter.Quantile("missing", 99), int64(0))
+
+	// Counters query data is preserved across publish cycles
+	meter.metrics.Reset()
+	meter.metrics.Counter("foo").Add()
+	st.Expect(t, meter.Counter("foo"), uint64(61))
+	st.Expect(t, meter.Rate("foo", time.Minute), float64(61)/60)
+	st.Expect(t, meter.Quantile("baz", 99), int64(200))
+
+	// Quantiles are answered from the last completed publish cycle
+	meter.metrics.Histogram("bar").RecordValue(300)
+	st.Expect(t, meter.Quantile("bar", 99), int64(100))
+	meter.metrics.Reset()
+	st.Expect(t, meter.Quantile("bar", 99), int64(300))
+	meter.metrics.Reset()
+	st.Expect(t, meter.Quantile("bar", 99), int64(0))
+
+	// Query methods don't create series
+	_, ok := meter.metrics.Snapshot().Counters["missing"]
+	st.Expect(t, ok, false)
+}
+
+func TestMeterQueryExpired(t *testing.T) {
+	meter := &Meter{metrics: NewMetrics(), quit: make(chan bool)}
+	defer meter.metrics.Reset()
+
+	meter.metrics.Counter("foo").AddN(5)
+	st.Expect(t, meter.Counter("foo"), uint64(5))
+
+	// Idle counters are removed, restarting their total
+	for x := 0; x <= SeriesTTL; x++ {
+		meter.metrics.Reset()
+	}
+	st.Expect(t, meter.Counter("foo"), uint64(0))
+	meter.metrics.Counter("foo").Add()
+	st.Expect(t, meter.Counter("foo"), uint64(1))
+}
+
+func TestMeterQueryCumulative(t *testing.T) {
+	meter := &Meter{metrics: NewMetrics(), quit: make(chan bool)}
+	defer meter.metrics.Reset()
+
+	meter.metrics.Histogram("foo").RecordValue(100)
+	meter.metrics.Reset()
+	st.Expect(t, meter.Quantile("foo", 99), int64(100))
+
+	// Cumulative histograms are kept across cycles
+	meter.SetCumulative(true)
+	meter.metrics.Histogram("foo").RecordValue(300)
+	meter.metrics.Expire(SeriesTTL)
+	st.Expect(t, meter.Quantile("foo", 99), int64(300))
+	meter.metrics.Histogram("foo").RecordValue(500)
+	st.Expect(t, meter.Quantile("foo", 50), int64(300))
+	st.Expect(t, meter.Quantile("foo", 99), int64(500))
+}
+
+func TestLiveCounter(t *testing.T) {
+	live := newLiveCounter()
+	now := time.Unix(1000, 0)
+	live.add(now, 10)
+	live.add(now.Add(30*time.Second), 5)
+
+	st.Expect(t, live.sum(now.Add(30*time.Second), time.Second), uint64(5))
+	st.Expect(t, live.sum(now.Add(30*time.Second), time.Minute), uint64(15))
+	st.Expect(t, live.sum(now.Add(time.Hour), time.Minute), uint64(0))
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// PublishRates enables the 1, 5 and 15-minute request and error rates
+// in the built-in MeterNumberOfRequests and MeterResponseStatus meters.
+// Defaults to false.
+var PublishRates = false
+
+// rateTickInterval defines the interval between moving average updates.
+const rateTickInterval = 5 * time.Second
+
+// Rate implements a rate metric which tracks the mean rate and the 1, 5 and 15-minute
+// exponentially weighted moving average rates of marked events per second,
+// similar to Dropwizard meters.
+//
+// Unlike other metrics, rates are preserved across publish cycles.
+// Rate is designed to be safety used by multiple goroutines.
+type Rate struct {
+	sync.Mutex
+	count    uint64
+	start    time.Time
+	lastTick time.Time
+	m1       *ewma
+	m5       *ewma
+	m15      *ewma
+}
+
+// NewRate creates a new rate metric.
+func NewRate() *Rate {
+	now := time.Now()
+	return &Rate{
+		start:    now,
+		lastTick: now,
+		m1:       newEWMA(1),
+		m5:       newEWMA(5),
+		m15:      newEWMA(15),
+	}
+}
+
+// Mark registers a new event.
+func (r *Rate) Mark() {
+	r.MarkN(1)
+}
+
+// MarkN registers n new events.
+func (r *Rate) MarkN(n uint64) {
+	r.Lock()
+	defer r.Unlock()
+	r.tick(time.Now())
+	r.count += n
+	r.m1.update(n)
+	r.m5.update(n)
+	r.m15.update(n)
+}
+
+// Count returns the total number of marked events.
+func (r *Rate) Count() uint64 {
+	r.Lock()
+	defer r.Unlock()
+	return r.count
+}
+
+// RateMean returns the mean rate of events per second since the rate was created.
+func (r *Rate) RateMean() float64 {
+	r.Lock()
+	defer r.Unlock()
+	elapsed := time.Since(r.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(r.count) / elapsed
+}
+
+// Rate1 returns the 1-minute moving average rate of events per second.
+func (r *Rate) Rate1() float64 {
+	return r.rate(r.m1)
+}
+
+// Rate5 returns the 5-minute moving average rate of events per second.
+func (r *Rate) Rate5() float64 {
+	return r.rate(r.m5)
+}
+
+// Rate15 returns the 15-minute moving average rate of events per second.
+func (r *Rate) Rate15() float64 {
+	return r.rate(r.m15)
+}
+
+func (r *Rate) rate(e *ewma) float64 {
+	r.Lock()
+	defer r.Unlock()
+	r.tick(time.Now())
+	return e.rate
+}
+
+// tick updates the moving averages for every tick interval elapsed
+// since the last update. Caller must hold the lock.
+func (r *Rate) tick(now time.Time) {
+	ticks := int(now.Sub(r.lastTick) / rateTickInterval)
+	for x := 0; x < ticks; x++ {
+		r.m1.tick()
+		r.m5.tick()
+		r.m15.tick()
+	}
+	r.lastTick = r.lastTick.Add(time.Duration(ticks) * rateTickInterval)
+}
+
+// ewma implements an exponentially weighted moving average.
+type ewma struct {
+	alpha     float64
+	rate      float64
+	uncounted uint64
+	init      bool
+}
+
+// newEWMA creates a new moving average for the given amount of minutes.
+func newEWMA(minutes float64) *ewma {
+	return &ewma{alpha: 1 - math.Exp(-rateTickInterval.Seconds()/60/minutes)}
+}
+
+func (e *ewma) update(n uint64) {
+	e.uncounted += n
+}
+
+func (e *ewma) tick() {
+	instant := float64(e.uncounted) / rateTickInterval.Seconds()
+	e.uncounted = 0
+	if e.init {
+		e.rate += e.alpha * (instant - e.rate)
+		return
+	}
+	e.rate = instant
+	e.init = true
+}
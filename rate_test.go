@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbio/st"
+)
+
+func TestRate(t *testing.T) {
+	rate := NewRate()
+	rate.MarkN(10)
+	rate.Mark()
+	st.Expect(t, rate.Count(), uint64(11))
+	st.Expect(t, rate.RateMean() > 0, true)
+
+	// First tick initializes the moving averages with the instant rate
+	rate.Lock()
+	rate.tick(rate.lastTick.Add(rateTickInterval))
+	rate.Unlock()
+	st.Expect(t, rate.m1.rate, 2.2)
+	st.Expect(t, rate.m15.rate, 2.2)
+
+	// Following ticks decay the rates
+	rate.Lock()
+	rate.tick(rate.lastTick.Add(time.Minute))
+	rate.Unlock()
+	st.Expect(t, rate.m1.rate < 1, true)
+	st.Expect(t, rate.m1.rate < rate.m5.rate, true)
+	st.Expect(t, rate.m5.rate < rate.m15.rate, true)
+}
+
+func TestMeterRates(t *testing.T) {
+	PublishRates = true
+	defer func() { PublishRates = false }()
+
+	info, metrics := createMetrics()
+	defer metrics.Reset()
+	MeterNumberOfRequests(info, metrics)
+	info.Status = 500
+	MeterResponseStatus(info, metrics)
+
+	st.Expect(t, metrics.Rate("req.rate").Count(), uint64(1))
+	st.Expect(t, metrics.Rate("res.error.rate").Count(), uint64(1))
+
+	metrics.Reset()
+	st.Expect(t, metrics.Rate("req.rate").Count(), uint64(1))
+	gauges := metrics.Snapshot().FloatGauges
+	_, ok := gauges["req.rate.m1"]
+	st.Expect(t, ok, true)
+	st.Expect(t, gauges["req.rate.mean"] > 0, true)
+}
@@ -0,0 +1,318 @@
+package graphite
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/vinxi/metrics.v0"
+)
+
+// DefaultAddress defines the default Graphite plaintext protocol address.
+var DefaultAddress = "localhost:2003"
+
+// BatchSize defines the default maximum number of metrics sent per pickle message.
+var BatchSize = 500
+
+// DialTimeout defines the maximum amount of time to wait for a connection.
+var DialTimeout = 5 * time.Second
+
+// WriteTimeout defines the maximum amount of time to wait for every write to the server.
+var WriteTimeout = 5 * time.Second
+
+// MinBackoff defines the initial amount of time to wait before reconnecting after a failure.
+var MinBackoff = time.Second
+
+// MaxBackoff defines the maximum amount of time to wait before reconnecting after a failure.
+var MaxBackoff = time.Minute
+
+// ErrBackoff is returned when the metrics are not sent since the reporter is waiting to reconnect.
+var ErrBackoff = errors.New("graphite: waiting to reconnect")
+
+// Config stores the Graphite reporter settings.
+type Config struct {
+	// Address stores the Graphite server TCP address. Defaults to DefaultAddress.
+	Address string
+	// Prefix stores an optional path prefix template added to all the metrics.
+	// Supports the {host} and {app} placeholders, e.g: "vinxi.{app}.{host}".
+	Prefix string
+	// App stores the application name used in the prefix template.
+	App string
+	// Pickle enables the pickle protocol, which sends metrics in batches.
+	// Note the pickle protocol listens on a different port, usually 2004.
+	Pickle bool
+	// BatchSize stores the maximum number of metrics per pickle message. Defaults to BatchSize.
+	BatchSize int
+}
+
+// Reporter implements a Graphite metrics reporter which sends data to a Carbon server via TCP.
+type Reporter struct {
+	sync.Mutex
+	config  Config
+	prefix  string
+	conn    net.Conn
+	backoff time.Duration
+	retry   time.Time
+}
+
+// metric represents a Graphite metric data point.
+type metric struct {
+	path  string
+	value float64
+}
+
+// New creates a new Graphite reporter which will send the metrics to the specified server.
+func New(c Config) *Reporter {
+	if c.Address == "" {
+		c.Address = DefaultAddress
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = BatchSize
+	}
+	return &Reporter{config: c, prefix: prefix(c)}
+}
+
+// Report implements the metrics.Reporter interface.
+func (r *Reporter) Report(re metrics.Report) error {
+	err := r.send(r.mapReport(re), time.Now())
+	if err != nil {
+		log.Printf("graphite: error sending metrics err=%v", err)
+	}
+	return err
+}
+
+// Close closes the underlying connection, if any.
+func (r *Reporter) Close() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+func (r *Reporter) send(points []metric, now time.Time) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if err := r.connect(now); err != nil {
+		return err
+	}
+
+	var err error
+	if r.config.Pickle {
+		err = r.writePickle(points, now)
+	} else {
+		err = r.writePlaintext(points, now)
+	}
+
+	// Drop the broken connection, reconnecting on the next report
+	if err != nil {
+		r.conn.Close()
+		r.conn = nil
+		r.fail(now)
+	}
+	return err
+}
+
+// connect dials the Graphite server, if not connected, unless it is waiting to reconnect.
+func (r *Reporter) connect(now time.Time) error {
+	if r.conn != nil {
+		return nil
+	}
+	if now.Before(r.retry) {
+		return ErrBackoff
+	}
+
+	conn, err := net.DialTimeout("tcp", r.config.Address, DialTimeout)
+	if err != nil {
+		r.fail(now)
+		return err
+	}
+
+	r.conn = conn
+	r.backoff = 0
+	return nil
+}
+
+// fail schedules the next reconnection using an exponential backoff.
+func (r *Reporter) fail(now time.Time) {
+	r.backoff *= 2
+	if r.backoff < MinBackoff {
+		r.backoff = MinBackoff
+	}
+	if r.backoff > MaxBackoff {
+		r.backoff = MaxBackoff
+	}
+	r.retry = now.Add(r.backoff)
+}
+
+func (r *Reporter) writePlaintext(points []metric, now time.Time) error {
+	w := bufio.NewWriter(deadlineWriter{r.conn})
+	for _, point := range points {
+		fmt.Fprintf(w, "%s %s %d\n", point.path, formatFloat(point.value), now.Unix())
+	}
+	return w.Flush()
+}
+
+func (r *Reporter) writePickle(points []metric, now time.Time) error {
+	for len(points) > 0 {
+		size := r.config.BatchSize
+		if size > len(points) {
+			size = len(points)
+		}
+
+		payload := pickle(points[:size], now.Unix())
+		header := make([]byte, 4)
+		binary.BigEndian.PutUint32(header, uint32(len(payload)))
+		if _, err := (deadlineWriter{r.conn}).Write(append(header, payload...)); err != nil {
+			return err
+		}
+
+		points = points[size:]
+	}
+	return nil
+}
+
+// deadlineWriter writes to the connection, failing if the write doesn't complete
+// within the WriteTimeout, so stalled servers don't block the reporter.
+type deadlineWriter struct {
+	conn net.Conn
+}
+
+func (w deadlineWriter) Write(data []byte) (int, error) {
+	if err := w.conn.SetWriteDeadline(time.Now().Add(WriteTimeout)); err != nil {
+		return 0, err
+	}
+	return w.conn.Write(data)
+}
+
+func (r *Reporter) mapReport(re metrics.Report) []metric {
+	var points []metric
+
+	// Add counters
+	for key, value := range re.Counters {
+		points = append(points, metric{r.path(key), float64(value)})
+	}
+	for key, value := range re.FloatCounters {
+		points = append(points, metric{r.path(key), value})
+	}
+
+	// Add gauges, flattening the histogram percentiles into lower case leaves
+	for key, value := range re.Gauges {
+		points = append(points, metric{r.path(leaf(key)), float64(value)})
+	}
+	for key, value := range re.FloatGauges {
+		points = append(points, metric{r.path(key), value})
+	}
+
+	sort.Sort(byPath(points))
+	return points
+}
+
+// path returns the prefixed and sanitized metric path for the given key.
+func (r *Reporter) path(key string) string {
+	key = sanitize(key)
+	if r.prefix != "" {
+		return r.prefix + "." + key
+	}
+	return key
+}
+
+// leaf converts histogram percentile keys, such as "res.time.P99", into "res.time.p99".
+func leaf(key string) string {
+	index := strings.LastIndex(key, ".")
+	if index == -1 {
+		return key
+	}
+	if _, ok := metrics.Percentiles[key[index+1:]]; !ok {
+		return key
+	}
+	return key[:index+1] + strings.ToLower(key[index+1:])
+}
+
+// prefix renders the prefix template for the given config,
+// removing the empty path nodes left by empty placeholders.
+func prefix(c Config) string {
+	host, _ := os.Hostname()
+	replacer := strings.NewReplacer(
+		"{host}", strings.Replace(sanitize(host), ".", "_", -1),
+		"{app}", strings.Replace(sanitize(c.App), ".", "_", -1),
+	)
+	var nodes []string
+	for _, node := range strings.Split(replacer.Replace(c.Prefix), ".") {
+		if node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	return strings.Join(nodes, ".")
+}
+
+// sanitize replaces the characters not supported in Graphite paths.
+func sanitize(key string) string {
+	return strings.Map(func(c rune) rune {
+		switch c {
+		case ' ', '/', '\\', '\n', '\t', '\r':
+			return '_'
+		}
+		return c
+	}, key)
+}
+
+// pickle encodes the given metrics as a pickled list of (path, (timestamp, value)) tuples,
+// using the pickle protocol version 2.
+func pickle(points []metric, timestamp int64) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0x80, 0x02}) // PROTO 2
+	buf.WriteByte(']')            // EMPTY_LIST
+	buf.WriteByte('(')            // MARK
+
+	num := make([]byte, 8)
+	for _, point := range points {
+		// Path as BINUNICODE
+		buf.WriteByte('X')
+		binary.LittleEndian.PutUint32(num, uint32(len(point.path)))
+		buf.Write(num[:4])
+		buf.WriteString(point.path)
+
+		// Timestamp as BININT
+		buf.WriteByte('J')
+		binary.LittleEndian.PutUint32(num, uint32(int32(timestamp)))
+		buf.Write(num[:4])
+
+		// Value as BINFLOAT
+		buf.WriteByte('G')
+		binary.BigEndian.PutUint64(num, math.Float64bits(point.value))
+		buf.Write(num)
+
+		buf.WriteByte(0x86) // TUPLE2 (timestamp, value)
+		buf.WriteByte(0x86) // TUPLE2 (path, (timestamp, value))
+	}
+
+	buf.WriteByte('e') // APPENDS
+	buf.WriteByte('.') // STOP
+	return buf.Bytes()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+type byPath []metric
+
+func (p byPath) Len() int           { return len(p) }
+func (p byPath) Less(i, j int) bool { return p[i].path < p[j].path }
+func (p byPath) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
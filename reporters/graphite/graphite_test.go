@@ -0,0 +1,150 @@
+package graphite
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbio/st"
+	"gopkg.in/vinxi/metrics.v0"
+)
+
+func listen(t *testing.T) (net.Listener, chan []byte) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	st.Assert(t, err, nil)
+
+	data := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 65536)
+		n, _ := io.ReadAtLeast(bufio.NewReader(conn), buf, 1)
+		data <- buf[:n]
+	}()
+	return ln, data
+}
+
+func receive(t *testing.T, data chan []byte) []byte {
+	select {
+	case buf := <-data:
+		return buf
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for metrics")
+	}
+	return nil
+}
+
+func TestReportPlaintext(t *testing.T) {
+	ln, data := listen(t)
+	defer ln.Close()
+
+	reporter := New(Config{Address: ln.Addr().String(), Prefix: "vinxi.{app}", App: "proxy.a"})
+	defer reporter.Close()
+
+	report := metrics.Report{
+		Counters:    map[string]uint64{"req.total": 10},
+		Gauges:      map[string]int64{"res.time.P99": 5},
+		FloatGauges: map[string]float64{"res.apdex": 0.5},
+	}
+	now := time.Unix(1000, 0)
+	st.Expect(t, reporter.send(reporter.mapReport(report), now), nil)
+	st.Expect(t, string(receive(t, data)), strings.Join([]string{
+		"vinxi.proxy_a.req.total 10 1000",
+		"vinxi.proxy_a.res.apdex 0.5 1000",
+		"vinxi.proxy_a.res.time.p99 5 1000",
+	}, "\n")+"\n")
+}
+
+func TestReportPickle(t *testing.T) {
+	ln, data := listen(t)
+	defer ln.Close()
+
+	reporter := New(Config{Address: ln.Addr().String(), Pickle: true})
+	defer reporter.Close()
+
+	points := []metric{{"foo", 1}}
+	st.Expect(t, reporter.send(points, time.Unix(1000, 0)), nil)
+
+	buf := receive(t, data)
+	payload := pickle(points, 1000)
+	st.Expect(t, binary.BigEndian.Uint32(buf[:4]), uint32(len(payload)))
+	st.Expect(t, buf[4:], payload)
+}
+
+func TestPickle(t *testing.T) {
+	payload := pickle([]metric{{"a", 1}}, 1)
+	st.Expect(t, payload, []byte{
+		0x80, 0x02, ']', '(',
+		'X', 1, 0, 0, 0, 'a',
+		'J', 1, 0, 0, 0,
+		'G', 0x3f, 0xf0, 0, 0, 0, 0, 0, 0,
+		0x86, 0x86, 'e', '.',
+	})
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	st.Assert(t, err, nil)
+	address := ln.Addr().String()
+	ln.Close()
+
+	reporter := New(Config{Address: address})
+	now := time.Now()
+	st.Expect(t, reporter.send(nil, now) != nil, true)
+	st.Expect(t, reporter.send(nil, now.Add(MinBackoff/2)), ErrBackoff)
+	st.Expect(t, reporter.send(nil, now.Add(MinBackoff)) != ErrBackoff, true)
+	st.Expect(t, reporter.backoff, 2*MinBackoff)
+}
+
+func TestWriteTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	st.Assert(t, err, nil)
+	defer ln.Close()
+
+	// Accept connections without reading from them
+	stalled := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			stalled <- conn
+		}
+	}()
+
+	timeout := WriteTimeout
+	WriteTimeout = 100 * time.Millisecond
+	defer func() { WriteTimeout = timeout }()
+
+	reporter := New(Config{Address: ln.Addr().String()})
+	defer reporter.Close()
+
+	points := make([]metric, 500000)
+	for x := range points {
+		points[x] = metric{strings.Repeat("a", 100), 1}
+	}
+	st.Expect(t, reporter.send(points, time.Now()) != nil, true)
+	st.Expect(t, reporter.conn, nil)
+	(<-stalled).Close()
+}
+
+func TestPrefix(t *testing.T) {
+	host, _ := os.Hostname()
+	host = strings.Replace(host, ".", "_", -1)
+	st.Expect(t, prefix(Config{Prefix: "vinxi.{host}.{app}", App: "api"}), "vinxi."+host+".api")
+	st.Expect(t, prefix(Config{Prefix: "vinxi.{app}"}), "vinxi")
+	st.Expect(t, prefix(Config{Prefix: "vinxi.{app}.{host}", App: "api"}), "vinxi.api."+host)
+	st.Expect(t, prefix(Config{Prefix: "vinxi.{app}.proxy"}), "vinxi.proxy")
+	st.Expect(t, prefix(Config{Prefix: "{app}..vinxi"}), "vinxi")
+}
+
+func TestLeaf(t *testing.T) {
+	st.Expect(t, leaf("res.time.P999"), "res.time.p999")
+	st.Expect(t, leaf("res.time"), "res.time")
+}
@@ -1,43 +1,121 @@
 package influx
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	client "github.com/influxdata/influxdb/client/v2"
+	client "github.com/influxdata/influxdb1-client/v2"
 	"gopkg.in/vinxi/metrics.v0"
 )
 
-// TimePrecision defines the default time precision used in batch points.
+// TimePrecision defines the default time precision used in batch points,
+// when not defined via Config.Precision.
 // For more info, see: https://docs.influxdata.com/influxdb/v0.8/api/reading_and_writing_data/#time-precision-on-written-data
 var TimePrecision = "ms"
 
+// BatchSize defines the default maximum number of points sent per write request.
+var BatchSize = 5000
+
+// UDPPrefix defines the URL prefix used to write points via UDP, such as "udp://localhost:8089".
+const UDPPrefix = "udp://"
+
+// Timeout defines the maximum amount of time to wait for the InfluxDB 2.x write API response.
+var Timeout = 10 * time.Second
+
 // Config stores the InfluxDB connection params.
+// InfluxDB 1.x is written via Database, Username and Password,
+// while InfluxDB 2.x and 3.x are written via Org, Bucket and Token.
+// URLs prefixed by UDPPrefix write points via the InfluxDB UDP service.
 type Config struct {
 	URL      string
 	Database string
 	Username string
 	Password string
+	Org      string
+	Bucket   string
+	Token    string
 	Tags     map[string]string
+	// Precision stores the points time precision: "ns", "us", "ms" or "s". Defaults to TimePrecision.
+	Precision string
+	// Gzip enables gzip compression of the line protocol sent to the InfluxDB 2.x write API.
+	Gzip bool
+	// BatchSize stores the maximum number of points per write request. Defaults to BatchSize.
+	BatchSize int
+	// PayloadSize stores the maximum UDP datagram payload size. Defaults to client.UDPPayloadSize.
+	PayloadSize int
+	// Buckets stores the histogram buckets upper bounds. If defined, the cumulative count
+	// of every histogram bucket is written as a separate "<key>.bucket" point, tagged by "le".
+	Buckets []int64
+	// Naming stores the measurement naming strategy. Defaults to NamingSuffix.
+	Naming Naming
+	// Templates stores the graphite-like templates used to extract the measurement,
+	// field and tags from the dotted metric keys, such as "res.status.* measurement.measurement.status".
+	// Metrics not matching any template are named according to the naming strategy.
+	Templates []string
+	// FloatSuffix enables writing float metrics colliding with an integer metric in the same field,
+	// such as a gauge and a float gauge sharing a key, to the "<field>_float" field.
+	// Otherwise, the colliding value is dropped and reported via DroppedFieldsError.
+	FloatSuffix bool
 }
 
 // Reporter implements an InfluxDB metrics reporter who send data to a InfluxDB server via HTTP.
 type Reporter struct {
-	config Config
-	client client.Client
+	sync.Mutex
+	config    Config
+	client    client.Client
+	http      *http.Client
+	templates []*template
 }
 
 // New creates a new InfluxDB reporter which will post the metrics to the specified server.
 func New(c Config) *Reporter {
+	if c.Precision == "" {
+		c.Precision = TimePrecision
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = BatchSize
+	}
+
 	re := &Reporter{config: c}
+	for _, t := range c.Templates {
+		tmpl, err := parseTemplate(t)
+		if err != nil {
+			log.Printf("influxdb: invalid template err=%v", err)
+			continue
+		}
+		re.templates = append(re.templates, tmpl)
+	}
+
+	if c.v2() {
+		re.http = &http.Client{Timeout: Timeout}
+		return re
+	}
+
 	if err := re.makeClient(); err != nil {
 		log.Printf("unable to make InfluxDB client. err=%v", err)
 	}
 	return re
 }
 
+// v2 returns true if the InfluxDB 2.x write API should be used.
+func (c Config) v2() bool {
+	return !c.udp() && (c.Token != "" || c.Bucket != "")
+}
+
+// udp returns true if points should be written via UDP.
+func (c Config) udp() bool {
+	return strings.HasPrefix(c.URL, UDPPrefix)
+}
+
 // Report implements the metrics.Reporter interface.
 func (r *Reporter) Report(re metrics.Report) error {
 	err := r.send(re)
@@ -48,6 +126,15 @@ func (r *Reporter) Report(re metrics.Report) error {
 }
 
 func (r *Reporter) makeClient() (err error) {
+	if r.config.udp() {
+		// UDP client packs the points in datagrams up to the payload size
+		r.client, err = client.NewUDPClient(client.UDPConfig{
+			Addr:        strings.TrimPrefix(r.config.URL, UDPPrefix),
+			PayloadSize: r.config.PayloadSize,
+		})
+		return
+	}
+
 	r.client, err = client.NewHTTPClient(client.HTTPConfig{
 		Addr:     r.config.URL,
 		Username: r.config.Username,
@@ -58,66 +145,204 @@ func (r *Reporter) makeClient() (err error) {
 
 func (r *Reporter) send(re metrics.Report) error {
 	// Create a new batch to store data points
-	bp, err := client.NewBatchPoints(client.BatchPointsConfig{
-		Precision: TimePrecision,
+	bp, err := r.batch()
+	if err != nil {
+		return err
+	}
+
+	// Map metrics report into influx compatible points, still sending them if some fields were dropped
+	dropped := r.mapReport(re, bp)
+	if _, ok := dropped.(*DroppedFieldsError); dropped != nil && !ok {
+		return dropped
+	}
+
+	// Send data to InfluxDB server, splitting points in batches
+	points := bp.Points()
+	for len(points) > 0 {
+		size := r.config.BatchSize
+		if size > len(points) {
+			size = len(points)
+		}
+		if err := r.write(points[:size]); err != nil {
+			return err
+		}
+		points = points[size:]
+	}
+
+	return dropped
+}
+
+func (r *Reporter) batch() (client.BatchPoints, error) {
+	return client.NewBatchPoints(client.BatchPointsConfig{
+		Precision: precision(r.config.Precision),
 		Database:  r.config.Database,
 	})
+}
+
+func (r *Reporter) write(points []*client.Point) error {
+	if r.config.v2() {
+		return r.writeV2(points)
+	}
+
+	c, err := r.connect()
+	if err != nil {
+		return err
+	}
+
+	bp, err := r.batch()
+	if err != nil {
+		return err
+	}
+	bp.AddPoints(points)
+	return c.Write(bp)
+}
+
+// connect returns the InfluxDB client, creating it again if the previous attempt failed.
+func (r *Reporter) connect() (client.Client, error) {
+	r.Lock()
+	defer r.Unlock()
+	if r.client == nil {
+		if err := r.makeClient(); err != nil {
+			r.client = nil
+			return nil, err
+		}
+	}
+	return r.client, nil
+}
+
+// writeV2 writes the given points in line protocol via the InfluxDB 2.x write API,
+// which is also supported by InfluxDB 3.x.
+func (r *Reporter) writeV2(points []*client.Point) error {
+	var body bytes.Buffer
+	for _, pt := range points {
+		body.WriteString(pt.PrecisionString(precision(r.config.Precision)))
+		body.WriteByte('\n')
+	}
+
+	// Compress the line protocol, if enabled
+	if r.config.Gzip {
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		if _, err := zw.Write(body.Bytes()); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = compressed
+	}
+
+	query := url.Values{}
+	query.Set("org", r.config.Org)
+	query.Set("bucket", r.config.Bucket)
+	query.Set("precision", precisionV2(r.config.Precision))
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(r.config.URL, "/")+"/api/v2/write?"+query.Encode(), &body)
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if r.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+r.config.Token)
+	}
+	if r.config.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 
-	// Map metrics report into influx compatible points
-	if err := r.mapReport(re, bp); err != nil {
+	res, err := r.http.Do(req)
+	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
 
-	// Send data to InfluxDB server
-	return r.client.Write(bp)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("influxdb: write error status=%d body=%s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
 }
 
 func (r *Reporter) mapReport(re metrics.Report, bp client.BatchPoints) error {
-	now := time.Now()
+	points := r.newPoints()
 
 	// Extract histograms from standalone gauges
 	gauges, histograms := extractHistograms(re.Gauges)
 
+	// Add histogram summary statistics
+	for key := range re.Histograms {
+		if _, ok := histograms[key]; !ok {
+			histograms[key] = nil
+		}
+	}
+
 	// Add histograms
 	for key, hg := range histograms {
-		fields := map[string]interface{}{
-			"p50":  hg["P50"],
-			"p75":  hg["P75"],
-			"p90":  hg["P90"],
-			"p95":  hg["P95"],
-			"p99":  hg["P99"],
-			"p999": hg["P999"],
+		fields := map[string]interface{}{}
+		if hg != nil {
+			fields["p50"] = hg["P50"]
+			fields["p75"] = hg["P75"]
+			fields["p90"] = hg["P90"]
+			fields["p95"] = hg["P95"]
+			fields["p99"] = hg["P99"]
+			fields["p999"] = hg["P999"]
 		}
-		pt, err := client.NewPoint(fmt.Sprintf("%s.histogram", key), r.config.Tags, fields, now)
-		if err != nil {
+		if snapshot, ok := re.Histograms[key]; ok {
+			fields["count"] = snapshot.Count
+			fields["sum"] = snapshot.Mean * float64(snapshot.Count)
+			fields["min"] = snapshot.Min
+			fields["max"] = snapshot.Max
+			fields["mean"] = snapshot.Mean
+		}
+		points.add(key, "histogram", nil, fields)
+	}
+
+	// Add histogram buckets, if enabled
+	if len(r.config.Buckets) > 0 {
+		if err := r.mapBuckets(re.Histograms, points); err != nil {
 			return err
 		}
-		bp.AddPoint(pt)
 	}
 
 	// Add gauges
 	for key, value := range gauges {
-		fields := map[string]interface{}{"value": int64(value)}
-		pt, err := client.NewPoint(fmt.Sprintf("%s.gauge", key), r.config.Tags, fields, now)
-		if err != nil {
-			return err
-		}
-		bp.AddPoint(pt)
+		points.add(key, "gauge", nil, map[string]interface{}{"value": int64(value)})
 	}
 
 	// Add counters
 	for key, value := range re.Counters {
-		fields := map[string]interface{}{"value": int64(value)}
-		pt, err := client.NewPoint(fmt.Sprintf("%s.count", key), r.config.Tags, fields, now)
+		points.add(key, "count", nil, map[string]interface{}{"value": int64(value)})
+	}
+
+	// Add float gauges
+	for key, value := range re.FloatGauges {
+		points.add(key, "gauge", nil, map[string]interface{}{"value": value})
+	}
+
+	// Add float counters
+	for key, value := range re.FloatCounters {
+		points.add(key, "count", nil, map[string]interface{}{"value": value})
+	}
+
+	return points.write(bp, time.Now())
+}
+
+// mapBuckets adds a point per histogram bucket, storing the cumulative count
+// of values less than or equal to the bucket upper bound.
+func (r *Reporter) mapBuckets(histograms map[string]metrics.HistogramSnapshot, points *points) error {
+	for key, snapshot := range histograms {
+		hist, err := metrics.DecodeHistogram(snapshot.Data)
 		if err != nil {
 			return err
 		}
-		bp.AddPoint(pt)
-	}
 
+		counts := hist.CumulativeCounts(r.config.Buckets)
+		for x, bound := range r.config.Buckets {
+			tags := map[string]string{"le": strconv.FormatInt(bound, 10)}
+			points.add(key, "bucket", tags, map[string]interface{}{"count": counts[x]})
+		}
+		tags := map[string]string{"le": "+Inf"}
+		points.add(key, "bucket", tags, map[string]interface{}{"count": snapshot.Count})
+	}
 	return nil
 }
 
@@ -128,17 +353,14 @@ func extractHistograms(records map[string]int64) (map[string]int64, map[string]m
 	histograms := make(map[string]map[string]int64)
 
 	for key, value := range records {
-		parts := strings.Split(key, ".")
-		perc := parts[len(parts)-1]
-
 		// If not percentile, store as unique gauge
-		if !isPercentile(perc) {
+		name, perc, ok := metrics.SplitPercentile(key)
+		if !ok {
 			gauges[key] = value
 			continue
 		}
 
 		// Aggregate histogram percentiles
-		name := strings.Join(parts[0:len(parts)-1], ".")
 		store, ok := histograms[name]
 		if !ok {
 			store = make(map[string]int64)
@@ -150,7 +372,24 @@ func extractHistograms(records map[string]int64) (map[string]int64, map[string]m
 	return gauges, histograms
 }
 
-func isPercentile(key string) bool {
-	return key == "P50" || key == "P75" || key == "P90" ||
-		key == "P95" || key == "P99" || key == "P999"
+// precision returns the InfluxDB 1.x time precision for the given precision.
+func precision(p string) string {
+	switch p {
+	case "ns":
+		return "n"
+	case "us":
+		return "u"
+	}
+	return p
+}
+
+// precisionV2 returns the InfluxDB 2.x time precision for the given precision.
+func precisionV2(p string) string {
+	switch p {
+	case "n", "":
+		return "ns"
+	case "u":
+		return "us"
+	}
+	return p
 }
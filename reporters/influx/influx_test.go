@@ -1,15 +1,30 @@
 package influx
 
 import (
+	"compress/gzip"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
-	client "github.com/influxdata/influxdb/client/v2"
+	client "github.com/influxdata/influxdb1-client/v2"
 	"github.com/nbio/st"
 	"gopkg.in/vinxi/metrics.v0"
+	"gopkg.in/vinxi/metrics.v0/internal/metricstest"
 )
 
 var testConfig = Config{URL: "http://foo"}
 
+func fields(t *testing.T, pt *client.Point) map[string]interface{} {
+	values, err := pt.Fields()
+	st.Assert(t, err, nil)
+	return values
+}
+
 func TestMapReportCounters(t *testing.T) {
 	counters := make(map[string]uint64)
 	counters["foo"] = 100
@@ -21,7 +36,7 @@ func TestMapReportCounters(t *testing.T) {
 
 	st.Expect(t, len(bp.Points()), 1)
 	st.Expect(t, bp.Points()[0].Name(), "foo.count")
-	st.Expect(t, bp.Points()[0].Fields()["value"], int64(100))
+	st.Expect(t, fields(t, bp.Points()[0])["value"], int64(100))
 }
 
 func TestMapReportGauges(t *testing.T) {
@@ -35,7 +50,24 @@ func TestMapReportGauges(t *testing.T) {
 
 	st.Expect(t, len(bp.Points()), 1)
 	st.Expect(t, bp.Points()[0].Name(), "foo.gauge")
-	st.Expect(t, bp.Points()[0].Fields()["value"], int64(100))
+	st.Expect(t, fields(t, bp.Points()[0])["value"], int64(100))
+}
+
+func TestMapReportFloats(t *testing.T) {
+	report := metrics.Report{
+		FloatGauges:   map[string]float64{"foo": 0.5},
+		FloatCounters: map[string]float64{"bar": 1.5},
+	}
+	reporter := New(testConfig)
+
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{})
+	reporter.mapReport(report, bp)
+
+	st.Expect(t, len(bp.Points()), 2)
+	st.Expect(t, bp.Points()[0].Name(), "foo.gauge")
+	st.Expect(t, fields(t, bp.Points()[0])["value"], 0.5)
+	st.Expect(t, bp.Points()[1].Name(), "bar.count")
+	st.Expect(t, fields(t, bp.Points()[1])["value"], 1.5)
 }
 
 func TestMapReportHistograms(t *testing.T) {
@@ -55,15 +87,139 @@ func TestMapReportHistograms(t *testing.T) {
 	st.Expect(t, len(bp.Points()), 1)
 	histogram := bp.Points()[0]
 	st.Expect(t, histogram.Name(), "foo.histogram")
-	fields := histogram.Fields()
-	st.Expect(t, fields["p50"], int64(50))
-	st.Expect(t, fields["p75"], int64(75))
-	st.Expect(t, fields["p90"], int64(90))
-	st.Expect(t, fields["p95"], int64(95))
-	st.Expect(t, fields["p99"], int64(99))
-	st.Expect(t, fields["p999"], int64(999))
+	values := fields(t, histogram)
+	st.Expect(t, values["p50"], int64(50))
+	st.Expect(t, values["p75"], int64(75))
+	st.Expect(t, values["p90"], int64(90))
+	st.Expect(t, values["p95"], int64(95))
+	st.Expect(t, values["p99"], int64(99))
+	st.Expect(t, values["p999"], int64(999))
+}
+
+func TestMapReportHistogramStats(t *testing.T) {
+	snapshot := metricstest.Histogram(10, 30)
+	report := metrics.Report{
+		Gauges:     map[string]int64{"foo.P99": 30},
+		Histograms: map[string]metrics.HistogramSnapshot{"foo": snapshot},
+	}
+	reporter := New(Config{URL: "http://foo", Buckets: []int64{10, 100}, Tags: map[string]string{"host": "a"}})
+
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{})
+	st.Expect(t, reporter.mapReport(report, bp), nil)
+	st.Expect(t, len(bp.Points()), 4)
+
+	histogram := bp.Points()[0]
+	st.Expect(t, histogram.Name(), "foo.histogram")
+	values := fields(t, histogram)
+	st.Expect(t, values["p99"], int64(30))
+	st.Expect(t, values["count"], int64(2))
+	st.Expect(t, values["sum"], float64(40))
+	st.Expect(t, values["min"], int64(10))
+	st.Expect(t, values["max"], int64(30))
+	st.Expect(t, values["mean"], float64(20))
+
+	buckets := map[string]interface{}{}
+	for _, pt := range bp.Points()[1:] {
+		st.Expect(t, pt.Name(), "foo.bucket")
+		st.Expect(t, pt.Tags()["host"], "a")
+		buckets[pt.Tags()["le"]] = fields(t, pt)["count"]
+	}
+	st.Expect(t, buckets, map[string]interface{}{"10": int64(1), "100": int64(2), "+Inf": int64(2)})
 }
 
 func TestInfluxDataReport(t *testing.T) {
 	// TODO: mock InfluxDB server and assert reported JSON data
 }
+
+func TestReportV2(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st.Expect(t, r.URL.Path, "/api/v2/write")
+		st.Expect(t, r.URL.Query().Get("org"), "acme")
+		st.Expect(t, r.URL.Query().Get("bucket"), "metrics")
+		st.Expect(t, r.URL.Query().Get("precision"), "s")
+		st.Expect(t, r.Header.Get("Authorization"), "Token secret")
+		st.Expect(t, r.Header.Get("Content-Encoding"), "gzip")
+
+		zr, err := gzip.NewReader(r.Body)
+		st.Assert(t, err, nil)
+		body, _ := ioutil.ReadAll(zr)
+		requests = append(requests, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	reporter := New(Config{
+		URL:       server.URL,
+		Org:       "acme",
+		Bucket:    "metrics",
+		Token:     "secret",
+		Tags:      map[string]string{"host": "a"},
+		Precision: "s",
+		Gzip:      true,
+		BatchSize: 1,
+	})
+	report := metrics.Report{Counters: map[string]uint64{"foo": 1, "bar": 2}}
+	st.Expect(t, reporter.Report(report), nil)
+
+	st.Expect(t, len(requests), 2)
+	line := regexp.MustCompile(`^(foo|bar)\.count,host=a value=\d+i \d{10}\n$`)
+	st.Expect(t, line.MatchString(requests[0]), true)
+	st.Expect(t, line.MatchString(requests[1]), true)
+}
+
+func TestReportV2Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":"unauthorized"}`))
+	}))
+	defer server.Close()
+
+	reporter := New(Config{URL: server.URL, Bucket: "metrics"})
+	err := reporter.Report(metrics.Report{Counters: map[string]uint64{"foo": 1}})
+	st.Expect(t, err != nil, true)
+	st.Expect(t, strings.Contains(err.Error(), "unauthorized"), true)
+}
+
+func TestReportClientError(t *testing.T) {
+	reporter := New(Config{URL: "://invalid"})
+	st.Expect(t, reporter.client, nil)
+
+	err := reporter.Report(metrics.Report{Counters: map[string]uint64{"foo": 1}})
+	st.Expect(t, err != nil, true)
+}
+
+func TestPrecision(t *testing.T) {
+	st.Expect(t, precision("ns"), "n")
+	st.Expect(t, precision("us"), "u")
+	st.Expect(t, precision("ms"), "ms")
+	st.Expect(t, precisionV2("n"), "ns")
+	st.Expect(t, precisionV2("u"), "us")
+	st.Expect(t, precisionV2("s"), "s")
+}
+
+func TestReportUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	st.Assert(t, err, nil)
+	defer conn.Close()
+
+	reporter := New(Config{URL: UDPPrefix + conn.LocalAddr().String(), PayloadSize: 64})
+	report := metrics.Report{Counters: map[string]uint64{"foo": 1, "bar": 2, "baz": 3}}
+	st.Expect(t, reporter.Report(report), nil)
+
+	// Points are packed in datagrams up to the payload size
+	var lines []string
+	buf := make([]byte, 1024)
+	for len(lines) < 3 {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		st.Assert(t, err, nil)
+		st.Expect(t, n <= 64, true)
+		lines = append(lines, strings.Split(strings.TrimSpace(string(buf[:n])), "\n")...)
+	}
+
+	st.Expect(t, len(lines), 3)
+	for _, line := range lines {
+		st.Expect(t, regexp.MustCompile(`^(foo|bar|baz)\.count value=\di \d+$`).MatchString(line), true)
+	}
+}
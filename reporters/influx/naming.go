@@ -0,0 +1,288 @@
+package influx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+// Naming represents a measurement naming strategy.
+type Naming int
+
+const (
+	// NamingSuffix names the measurements by the metric key suffixed by its kind,
+	// such as "<key>.count", "<key>.gauge" or "<key>.histogram", storing the value in the "value" field.
+	NamingSuffix Naming = iota
+
+	// NamingGrouped groups the metrics into the "count", "gauge", "histogram" and "bucket"
+	// measurements by kind, storing each metric in a field named by the metric key.
+	NamingGrouped
+)
+
+// DroppedFieldsError is returned when metric fields are dropped since they collide with
+// another field of the same point. The remaining points are sent anyway.
+type DroppedFieldsError struct {
+	// Fields stores the dropped fields as "<measurement>:<field>".
+	Fields []string
+}
+
+// Error implements the error interface.
+func (e *DroppedFieldsError) Error() string {
+	return "influxdb: dropped duplicated fields: " + strings.Join(e.Fields, ", ")
+}
+
+// point represents a pending InfluxDB point, aggregating the fields of the metrics
+// mapped into the same measurement and tags.
+type point struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]interface{}
+}
+
+// points maps metrics into InfluxDB points according to the configured naming.
+type points struct {
+	reporter *Reporter
+	index    map[string]*point
+	list     []*point
+	dropped  []string
+}
+
+func (r *Reporter) newPoints() *points {
+	return &points{reporter: r, index: make(map[string]*point)}
+}
+
+// add maps the given metric fields into a point.
+func (p *points) add(key, kind string, tags map[string]string, fields map[string]interface{}) {
+	measurement, prefix, extracted := p.reporter.name(key, kind)
+
+	all := make(map[string]string, len(tags)+len(extracted)+len(p.reporter.config.Tags))
+	for _, m := range []map[string]string{p.reporter.config.Tags, extracted, tags} {
+		for name, value := range m {
+			all[name] = value
+		}
+	}
+
+	id := measurement + "," + formatTags(all)
+	pt, ok := p.index[id]
+	if !ok {
+		pt = &point{measurement: measurement, tags: all, fields: make(map[string]interface{})}
+		p.index[id] = pt
+		p.list = append(p.list, pt)
+	}
+
+	for name, value := range fields {
+		name = fieldName(prefix, name)
+		if !pt.set(name, value, p.reporter.config.FloatSuffix) {
+			p.dropped = append(p.dropped, measurement+":"+name)
+		}
+	}
+}
+
+// set sets the given point field, returning false if the value is dropped since the field is taken.
+// Since InfluxDB fields have a fixed type, float values colliding with integer values are stored
+// in the "<name>_float" field instead, if suffix is enabled.
+func (pt *point) set(name string, value interface{}, suffix bool) bool {
+	current, exists := pt.fields[name]
+	if !exists {
+		pt.fields[name] = value
+		return true
+	}
+	if !suffix {
+		return false
+	}
+
+	_, taken := pt.fields[name+"_float"]
+	switch {
+	case !taken && isFloat(value) && !isFloat(current):
+		pt.fields[name+"_float"] = value
+	case !taken && !isFloat(value) && isFloat(current):
+		pt.fields[name+"_float"] = current
+		pt.fields[name] = value
+	default:
+		return false
+	}
+	return true
+}
+
+// isFloat returns true if the given field value is a float.
+func isFloat(value interface{}) bool {
+	_, ok := value.(float64)
+	return ok
+}
+
+// write adds the points to the given batch, returning a DroppedFieldsError if any field was dropped.
+func (p *points) write(bp client.BatchPoints, now time.Time) error {
+	for _, pt := range p.list {
+		point, err := client.NewPoint(pt.measurement, pt.tags, pt.fields, now)
+		if err != nil {
+			return err
+		}
+		bp.AddPoint(point)
+	}
+
+	if len(p.dropped) > 0 {
+		sort.Strings(p.dropped)
+		return &DroppedFieldsError{Fields: p.dropped}
+	}
+	return nil
+}
+
+// name returns the measurement, the field prefix and the extracted tags for the given
+// metric key and kind, using the first matching template or the naming strategy otherwise.
+func (r *Reporter) name(key, kind string) (string, string, map[string]string) {
+	for _, t := range r.templates {
+		if t.match(key) {
+			return t.apply(key)
+		}
+	}
+
+	if r.config.Naming == NamingGrouped {
+		return kind, key, nil
+	}
+	return fmt.Sprintf("%s.%s", key, kind), "", nil
+}
+
+// fieldName returns the field name for the given field prefix and name.
+func fieldName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	if name == "value" {
+		return prefix
+	}
+	return prefix + "." + name
+}
+
+// template represents a graphite-like template used to extract the measurement,
+// field and tags from dotted metric keys. Templates consist of an optional filter,
+// the template parts and optional default tags, such as:
+//
+//	res.status.* measurement.measurement.status kind=status
+//
+// Template parts can be "measurement", "field", a tag name, or empty to ignore the key segment.
+// The last part can be "measurement*" or "field*" to match the remaining key segments.
+type template struct {
+	filter []string
+	parts  []string
+	tags   map[string]string
+}
+
+// parseTemplate parses the given template definition.
+func parseTemplate(s string) (*template, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 3 {
+		return nil, fmt.Errorf("invalid template format: %q", s)
+	}
+
+	// Extract the optional default tags
+	var tags string
+	if last := fields[len(fields)-1]; len(fields) > 1 && strings.Contains(last, "=") {
+		tags = last
+		fields = fields[:len(fields)-1]
+	}
+
+	t := &template{tags: make(map[string]string)}
+	switch len(fields) {
+	case 1:
+		t.parts = strings.Split(fields[0], ".")
+	case 2:
+		t.filter = strings.Split(fields[0], ".")
+		t.parts = strings.Split(fields[1], ".")
+	default:
+		return nil, fmt.Errorf("invalid template format: %q", s)
+	}
+
+	if tags != "" {
+		for _, tag := range strings.Split(tags, ",") {
+			pair := strings.SplitN(tag, "=", 2)
+			if len(pair) != 2 || pair[0] == "" {
+				return nil, fmt.Errorf("invalid template tag: %q", tag)
+			}
+			t.tags[pair[0]] = pair[1]
+		}
+	}
+
+	for x, part := range t.parts {
+		if strings.HasSuffix(part, "*") && (x != len(t.parts)-1 || (part != "measurement*" && part != "field*")) {
+			return nil, fmt.Errorf("invalid template part: %q", part)
+		}
+	}
+
+	return t, nil
+}
+
+// match returns true if the template filter matches the given key.
+// Filter segments match a key segment, while "*" matches any segment.
+// A trailing "*" segment matches all the remaining key segments.
+func (t *template) match(key string) bool {
+	if t.filter == nil {
+		return true
+	}
+
+	segments := strings.Split(key, ".")
+	for x, filter := range t.filter {
+		if x >= len(segments) {
+			return false
+		}
+		if filter == "*" && x == len(t.filter)-1 {
+			return true
+		}
+		if filter != "*" && filter != segments[x] {
+			return false
+		}
+	}
+	return len(segments) == len(t.filter)
+}
+
+// apply extracts the measurement, the field prefix and the tags from the given key.
+func (t *template) apply(key string) (string, string, map[string]string) {
+	var measurement, field []string
+	values := make(map[string][]string)
+
+	segments := strings.Split(key, ".")
+	for x, segment := range segments {
+		if x >= len(t.parts) {
+			break
+		}
+
+		switch part := t.parts[x]; part {
+		case "":
+		case "measurement":
+			measurement = append(measurement, segment)
+		case "field":
+			field = append(field, segment)
+		case "measurement*":
+			measurement = append(measurement, segments[x:]...)
+		case "field*":
+			field = append(field, segments[x:]...)
+		default:
+			values[part] = append(values[part], segment)
+		}
+	}
+
+	tags := make(map[string]string, len(t.tags)+len(values))
+	for name, value := range t.tags {
+		tags[name] = value
+	}
+	for name, value := range values {
+		tags[name] = strings.Join(value, ".")
+	}
+
+	if len(measurement) == 0 {
+		measurement = []string{key}
+	}
+	return strings.Join(measurement, "."), strings.Join(field, "."), tags
+}
+
+// formatTags returns a canonical representation of the given tags.
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for name, value := range tags {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
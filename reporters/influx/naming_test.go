@@ -0,0 +1,161 @@
+package influx
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+	"github.com/nbio/st"
+	"gopkg.in/vinxi/metrics.v0"
+)
+
+func TestMapReportGrouped(t *testing.T) {
+	report := metrics.Report{
+		Counters: map[string]uint64{"req.total": 10, "res.status.200": 8},
+		Gauges:   map[string]int64{"foo.P99": 99},
+	}
+	reporter := New(Config{URL: "http://foo", Naming: NamingGrouped})
+
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{})
+	st.Expect(t, reporter.mapReport(report, bp), nil)
+	st.Expect(t, len(bp.Points()), 2)
+
+	st.Expect(t, bp.Points()[0].Name(), "histogram")
+	st.Expect(t, fields(t, bp.Points()[0])["foo.p99"], int64(99))
+	st.Expect(t, bp.Points()[1].Name(), "count")
+	st.Expect(t, fields(t, bp.Points()[1]), map[string]interface{}{"req.total": int64(10), "res.status.200": int64(8)})
+}
+
+func TestMapReportFieldCollision(t *testing.T) {
+	report := metrics.Report{
+		Gauges:        map[string]int64{"foo": 1},
+		FloatGauges:   map[string]float64{"foo": 0.5},
+		Counters:      map[string]uint64{"bar": 2},
+		FloatCounters: map[string]float64{"bar": 1.5},
+	}
+
+	// Colliding fields keep the first value and report the dropped ones
+	reporter := New(Config{URL: "http://foo"})
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{})
+	err := reporter.mapReport(report, bp)
+	st.Expect(t, err, &DroppedFieldsError{Fields: []string{"bar.count:value", "foo.gauge:value"}})
+	st.Expect(t, len(bp.Points()), 2)
+
+	points := map[string]*client.Point{}
+	for _, pt := range bp.Points() {
+		points[pt.Name()] = pt
+	}
+	st.Expect(t, fields(t, points["foo.gauge"]), map[string]interface{}{"value": int64(1)})
+	st.Expect(t, fields(t, points["bar.count"]), map[string]interface{}{"value": int64(2)})
+
+	// Colliding floats are written to the suffixed field, if enabled
+	reporter = New(Config{URL: "http://foo", FloatSuffix: true})
+	bp, _ = client.NewBatchPoints(client.BatchPointsConfig{})
+	st.Expect(t, reporter.mapReport(report, bp), nil)
+	for _, pt := range bp.Points() {
+		points[pt.Name()] = pt
+	}
+	st.Expect(t, fields(t, points["foo.gauge"]), map[string]interface{}{"value": int64(1), "value_float": 0.5})
+	st.Expect(t, fields(t, points["bar.count"]), map[string]interface{}{"value": int64(2), "value_float": 1.5})
+
+	// Float values are moved if the integer value is added later
+	pt := &point{fields: make(map[string]interface{})}
+	st.Expect(t, pt.set("value", 0.5, true), true)
+	st.Expect(t, pt.set("value", int64(1), true), true)
+	st.Expect(t, pt.set("value", int64(2), true), false)
+	st.Expect(t, pt.fields, map[string]interface{}{"value": int64(1), "value_float": 0.5})
+}
+
+func TestReportDroppedFields(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// Points are still sent when some fields are dropped
+	reporter := New(Config{URL: server.URL, Bucket: "metrics"})
+	err := reporter.Report(metrics.Report{
+		Gauges:      map[string]int64{"foo": 1},
+		FloatGauges: map[string]float64{"foo": 0.5},
+	})
+	st.Expect(t, err, error(&DroppedFieldsError{Fields: []string{"foo.gauge:value"}}))
+	st.Expect(t, strings.HasPrefix(body, "foo.gauge value=1i "), true)
+}
+
+func TestMapReportTemplates(t *testing.T) {
+	report := metrics.Report{
+		Counters: map[string]uint64{"res.status.200": 8, "res.status.500": 2, "req.total": 10},
+		Gauges:   map[string]int64{"res.time.P99": 99},
+	}
+	reporter := New(Config{
+		URL:  "http://foo",
+		Tags: map[string]string{"host": "a"},
+		Templates: []string{
+			"res.status.* measurement.field.code kind=http",
+			"res.* measurement.field",
+		},
+	})
+
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{})
+	st.Expect(t, reporter.mapReport(report, bp), nil)
+
+	points := map[string]*client.Point{}
+	for _, pt := range bp.Points() {
+		points[pt.Name()+","+formatTags(pt.Tags())] = pt
+	}
+	st.Expect(t, len(points), 4)
+
+	st.Expect(t, fields(t, points["res,host=a"])["time.p99"], int64(99))
+	st.Expect(t, fields(t, points["res,code=200,host=a,kind=http"]), map[string]interface{}{"status": int64(8)})
+	st.Expect(t, fields(t, points["res,code=500,host=a,kind=http"]), map[string]interface{}{"status": int64(2)})
+	st.Expect(t, fields(t, points["req.total.count,host=a"]), map[string]interface{}{"value": int64(10)})
+}
+
+func TestParseTemplate(t *testing.T) {
+	tmpl, err := parseTemplate("req.* measurement.measurement* env=prod,dc=eu")
+	st.Expect(t, err, nil)
+	st.Expect(t, tmpl.filter, []string{"req", "*"})
+	st.Expect(t, tmpl.parts, []string{"measurement", "measurement*"})
+	st.Expect(t, tmpl.tags, map[string]string{"env": "prod", "dc": "eu"})
+
+	tmpl, err = parseTemplate("measurement.host env=prod")
+	st.Expect(t, err, nil)
+	st.Expect(t, tmpl.filter, []string(nil))
+	st.Expect(t, tmpl.tags["env"], "prod")
+
+	_, err = parseTemplate("measurement*.field")
+	st.Expect(t, err != nil, true)
+	_, err = parseTemplate("a b c d")
+	st.Expect(t, err != nil, true)
+}
+
+func TestTemplateMatch(t *testing.T) {
+	tmpl, _ := parseTemplate("req.*.size measurement")
+	st.Expect(t, tmpl.match("req.body.size"), true)
+	st.Expect(t, tmpl.match("req.body.time"), false)
+	st.Expect(t, tmpl.match("req.body"), false)
+
+	tmpl, _ = parseTemplate("req.* measurement")
+	st.Expect(t, tmpl.match("req.path./foo.bar"), true)
+	st.Expect(t, tmpl.match("res.total"), false)
+}
+
+func TestTemplateApply(t *testing.T) {
+	tmpl, _ := parseTemplate("measurement..host.host.field*")
+	measurement, field, tags := tmpl.apply("cpu.x.a.b.load.avg")
+	st.Expect(t, measurement, "cpu")
+	st.Expect(t, field, "load.avg")
+	st.Expect(t, tags, map[string]string{"host": "a.b"})
+
+	tmpl, _ = parseTemplate("region")
+	measurement, field, tags = tmpl.apply("eu.req.total")
+	st.Expect(t, measurement, "eu.req.total")
+	st.Expect(t, field, "")
+	st.Expect(t, tags["region"], "eu")
+}
@@ -0,0 +1,369 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/vinxi/metrics.v0"
+	"gopkg.in/vinxi/metrics.v0/internal/keys"
+)
+
+// DefaultURL defines the default OTLP/HTTP collector metrics endpoint.
+var DefaultURL = "http://localhost:4318/v1/metrics"
+
+// Timeout defines the maximum amount of time to wait for the collector response.
+var Timeout = 10 * time.Second
+
+// MaxExponentialBuckets defines the maximum number of buckets used by exponential histograms.
+var MaxExponentialBuckets = 160
+
+// ScopeName defines the instrumentation scope name of the exported metrics.
+const ScopeName = "gopkg.in/vinxi/metrics.v0"
+
+// Config stores the OTLP exporter settings.
+type Config struct {
+	// URL stores the collector metrics endpoint. Defaults to DefaultURL.
+	URL string
+	// Headers stores additional HTTP headers sent to the collector, such as authentication ones.
+	Headers map[string]string
+	// Resource stores the resource attributes, such as "service.name".
+	Resource map[string]string
+	// Exponential enables exporting histograms as exponential histograms.
+	Exponential bool
+	// Buckets stores the explicit histogram buckets upper bounds. Defaults to metrics.Buckets.
+	Buckets []int64
+	// Client stores the HTTP client used to send the metrics.
+	Client *http.Client
+}
+
+// Reporter implements an OpenTelemetry metrics exporter which sends data
+// to an OpenTelemetry collector via OTLP/HTTP using protobuf encoding.
+type Reporter struct {
+	sync.Mutex
+	config Config
+	start  time.Time
+	last   time.Time
+}
+
+// New creates a new OTLP reporter which will post the metrics to the specified collector.
+func New(c Config) *Reporter {
+	if c.URL == "" {
+		c.URL = DefaultURL
+	}
+	if c.Buckets == nil {
+		c.Buckets = metrics.Buckets
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: Timeout}
+	}
+	now := time.Now()
+	return &Reporter{config: c, start: now, last: now}
+}
+
+// Report implements the metrics.Reporter interface.
+func (r *Reporter) Report(re metrics.Report) error {
+	err := r.send(re)
+	if err != nil {
+		log.Printf("otlp: error sending metrics err=%v", err)
+	}
+	return err
+}
+
+func (r *Reporter) send(re metrics.Report) error {
+	req, err := r.mapReport(re, time.Now())
+	if err != nil {
+		return err
+	}
+
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest("POST", r.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for name, value := range r.config.Headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	res, err := r.config.Client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("otlp: unexpected response status: %d", res.StatusCode)
+	}
+	return nil
+}
+
+// mapReport converts the given report into an OTLP export request.
+func (r *Reporter) mapReport(re metrics.Report, now time.Time) (*collectorpb.ExportMetricsServiceRequest, error) {
+	// Counters and histograms are deltas since the publish cycle start, unless cumulative
+	r.Lock()
+	start := r.last
+	r.last = now
+	r.Unlock()
+
+	temporality := metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	if re.Cumulative {
+		start = r.start
+		temporality = metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	}
+	if !re.Start.IsZero() {
+		start = re.Start
+	}
+
+	m := mapper{report: re, start: uint64(start.UnixNano()), now: uint64(now.UnixNano())}
+	var list []*metricspb.Metric
+
+	// Add counters as monotonic sums
+	for key, value := range re.Counters {
+		point := m.point(&metricspb.NumberDataPoint_AsInt{AsInt: int64(value)}, true)
+		list = append(list, m.sum(key, point, temporality))
+	}
+	for key, value := range re.FloatCounters {
+		point := m.point(&metricspb.NumberDataPoint_AsDouble{AsDouble: value}, true)
+		list = append(list, m.sum(key, point, temporality))
+	}
+
+	// Add gauges, skipping the histogram percentiles, exported as histograms
+	for key, value := range re.Gauges {
+		if re.IsHistogramPercentile(key) {
+			continue
+		}
+		list = append(list, m.gauge(key, m.point(&metricspb.NumberDataPoint_AsInt{AsInt: value}, false)))
+	}
+	for key, value := range re.FloatGauges {
+		list = append(list, m.gauge(key, m.point(&metricspb.NumberDataPoint_AsDouble{AsDouble: value}, false)))
+	}
+
+	// Add top-k entries as gauges with the item attribute
+	for key, entries := range re.TopK {
+		var points []*metricspb.NumberDataPoint
+		for _, entry := range entries {
+			point := m.point(&metricspb.NumberDataPoint_AsInt{AsInt: int64(entry.Count)}, false)
+			point.Attributes = attributes(map[string]string{"item": entry.Item})
+			points = append(points, point)
+		}
+		list = append(list, m.gauge(key, points...))
+	}
+
+	// Add histograms
+	for key, snapshot := range re.Histograms {
+		hist, err := metrics.DecodeHistogram(snapshot.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		metric := m.metric(key)
+		if r.config.Exponential {
+			metric.Data = &metricspb.Metric_ExponentialHistogram{ExponentialHistogram: &metricspb.ExponentialHistogram{
+				DataPoints:             []*metricspb.ExponentialHistogramDataPoint{m.exponential(snapshot, hist)},
+				AggregationTemporality: temporality,
+			}}
+		} else {
+			metric.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				DataPoints:             []*metricspb.HistogramDataPoint{m.histogram(snapshot, hist, r.config.Buckets)},
+				AggregationTemporality: temporality,
+			}}
+		}
+		list = append(list, metric)
+	}
+
+	sort.Sort(byName(list))
+
+	return &collectorpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: attributes(r.config.Resource)},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: ScopeName},
+				Metrics: list,
+			}},
+		}},
+	}, nil
+}
+
+// mapper stores the report context used to build the OTLP metrics.
+type mapper struct {
+	report metrics.Report
+	start  uint64
+	now    uint64
+}
+
+// metric creates a new metric described by the report metadata.
+func (m mapper) metric(key string) *metricspb.Metric {
+	meta := m.report.Metadata[key]
+	return &metricspb.Metric{Name: key, Description: meta.Description, Unit: meta.Unit}
+}
+
+// sum creates a new monotonic sum metric with the given data point.
+func (m mapper) sum(key string, point *metricspb.NumberDataPoint, temporality metricspb.AggregationTemporality) *metricspb.Metric {
+	metric := m.metric(key)
+	metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+		DataPoints:             []*metricspb.NumberDataPoint{point},
+		AggregationTemporality: temporality,
+		IsMonotonic:            true,
+	}}
+	return metric
+}
+
+// gauge creates a new gauge metric with the given data points.
+func (m mapper) gauge(key string, points ...*metricspb.NumberDataPoint) *metricspb.Metric {
+	metric := m.metric(key)
+	metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: points}}
+	return metric
+}
+
+// point creates a new number data point. Only aggregated points carry a start time.
+func (m mapper) point(value interface{}, aggregated bool) *metricspb.NumberDataPoint {
+	point := &metricspb.NumberDataPoint{TimeUnixNano: m.now}
+	if aggregated {
+		point.StartTimeUnixNano = m.start
+	}
+	switch value := value.(type) {
+	case *metricspb.NumberDataPoint_AsInt:
+		point.Value = value
+	case *metricspb.NumberDataPoint_AsDouble:
+		point.Value = value
+	}
+	return point
+}
+
+// histogram creates an explicit buckets histogram data point.
+func (m mapper) histogram(snapshot metrics.HistogramSnapshot, hist *metrics.Histogram, buckets []int64) *metricspb.HistogramDataPoint {
+	sum, min, max := snapshot.Mean*float64(snapshot.Count), float64(snapshot.Min), float64(snapshot.Max)
+	point := &metricspb.HistogramDataPoint{
+		StartTimeUnixNano: m.start,
+		TimeUnixNano:      m.now,
+		Count:             uint64(snapshot.Count),
+		Sum:               &sum,
+		Min:               &min,
+		Max:               &max,
+		Exemplars:         exemplars(snapshot.Exemplars),
+	}
+
+	// Convert cumulative counts into per bucket counts
+	var previous int64
+	for x, count := range hist.CumulativeCounts(buckets) {
+		point.ExplicitBounds = append(point.ExplicitBounds, float64(buckets[x]))
+		point.BucketCounts = append(point.BucketCounts, uint64(count-previous))
+		previous = count
+	}
+	point.BucketCounts = append(point.BucketCounts, uint64(snapshot.Count-previous))
+
+	return point
+}
+
+// exponential creates an exponential buckets histogram data point, using the highest
+// scale which fits the recorded values range into MaxExponentialBuckets.
+func (m mapper) exponential(snapshot metrics.HistogramSnapshot, hist *metrics.Histogram) *metricspb.ExponentialHistogramDataPoint {
+	sum, min, max := snapshot.Mean*float64(snapshot.Count), float64(snapshot.Min), float64(snapshot.Max)
+	point := &metricspb.ExponentialHistogramDataPoint{
+		StartTimeUnixNano: m.start,
+		TimeUnixNano:      m.now,
+		Count:             uint64(snapshot.Count),
+		Sum:               &sum,
+		Min:               &min,
+		Max:               &max,
+		Positive:          &metricspb.ExponentialHistogramDataPoint_Buckets{},
+		Exemplars:         exemplars(snapshot.Exemplars),
+	}
+
+	values := hist.Values()
+	var lowest, highest int64
+	for _, value := range values {
+		if value.Value <= 0 {
+			continue
+		}
+		if lowest == 0 {
+			lowest = value.Value
+		}
+		highest = value.Value
+	}
+
+	scale := int32(20)
+	if lowest > 0 {
+		for scale > -10 && exponentialIndex(highest, scale)-exponentialIndex(lowest, scale) >= int32(MaxExponentialBuckets) {
+			scale--
+		}
+		point.Positive.Offset = exponentialIndex(lowest, scale)
+	}
+	point.Scale = scale
+
+	for _, value := range values {
+		if value.Value <= 0 {
+			point.ZeroCount += uint64(value.Count)
+			continue
+		}
+		index := int(exponentialIndex(value.Value, scale) - point.Positive.Offset)
+		for len(point.Positive.BucketCounts) <= index {
+			point.Positive.BucketCounts = append(point.Positive.BucketCounts, 0)
+		}
+		point.Positive.BucketCounts[index] += uint64(value.Count)
+	}
+
+	return point
+}
+
+// exponentialIndex returns the exponential bucket index for the given value and scale,
+// where bucket index i contains the values in the range (base^i, base^(i+1)].
+func exponentialIndex(value int64, scale int32) int32 {
+	return int32(math.Ceil(math.Log2(float64(value))*math.Ldexp(1, int(scale)))) - 1
+}
+
+// exemplars converts the histogram exemplars. Trace IDs not compliant
+// with the W3C trace context format are exported as attribute.
+func exemplars(list []metrics.Exemplar) []*metricspb.Exemplar {
+	var result []*metricspb.Exemplar
+	for _, exemplar := range list {
+		ex := &metricspb.Exemplar{
+			TimeUnixNano: uint64(exemplar.Timestamp.UnixNano()),
+			Value:        &metricspb.Exemplar_AsInt{AsInt: exemplar.Value},
+		}
+		if id, err := hex.DecodeString(exemplar.TraceID); err == nil && len(id) == 16 {
+			ex.TraceId = id
+		} else {
+			ex.FilteredAttributes = attributes(map[string]string{"trace_id": exemplar.TraceID})
+		}
+		result = append(result, ex)
+	}
+	return result
+}
+
+// attributes converts the given map into sorted OTLP string attributes.
+func attributes(m map[string]string) []*commonpb.KeyValue {
+	var attrs []*commonpb.KeyValue
+	for _, key := range keys.Sorted(m) {
+		attrs = append(attrs, &commonpb.KeyValue{
+			Key:   key,
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: m[key]}},
+		})
+	}
+	return attrs
+}
+
+type byName []*metricspb.Metric
+
+func (m byName) Len() int           { return len(m) }
+func (m byName) Less(i, j int) bool { return m[i].Name < m[j].Name }
+func (m byName) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
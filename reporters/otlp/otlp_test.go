@@ -0,0 +1,141 @@
+package otlp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbio/st"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/vinxi/metrics.v0"
+	"gopkg.in/vinxi/metrics.v0/internal/metricstest"
+)
+
+func find(req *collectorpb.ExportMetricsServiceRequest, name string) *metricspb.Metric {
+	for _, metric := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if metric.Name == name {
+			return metric
+		}
+	}
+	return nil
+}
+
+func TestReport(t *testing.T) {
+	received := make(chan *collectorpb.ExportMetricsServiceRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st.Expect(t, r.URL.Path, "/v1/metrics")
+		st.Expect(t, r.Header.Get("Content-Type"), "application/x-protobuf")
+		st.Expect(t, r.Header.Get("Authorization"), "Bearer token")
+		body, _ := ioutil.ReadAll(r.Body)
+		req := &collectorpb.ExportMetricsServiceRequest{}
+		st.Expect(t, proto.Unmarshal(body, req), nil)
+		received <- req
+	}))
+	defer server.Close()
+
+	reporter := New(Config{
+		URL:      server.URL + "/v1/metrics",
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+		Resource: map[string]string{"service.name": "proxy"},
+	})
+	st.Expect(t, reporter.Report(metrics.Report{Counters: map[string]uint64{"req.total": 3}}), nil)
+
+	req := <-received
+	resource := req.ResourceMetrics[0].Resource.Attributes
+	st.Expect(t, resource[0].Key, "service.name")
+	st.Expect(t, resource[0].Value.GetStringValue(), "proxy")
+	st.Expect(t, find(req, "req.total").GetSum().DataPoints[0].GetAsInt(), int64(3))
+}
+
+func TestReportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	reporter := New(Config{URL: server.URL})
+	st.Expect(t, reporter.Report(metrics.Report{}) != nil, true)
+}
+
+func TestMapReport(t *testing.T) {
+	reporter := New(Config{Buckets: []int64{10, 100}})
+	snapshot := metricstest.Histogram(5, 50, 500)
+	snapshot.Exemplars = []metrics.Exemplar{
+		{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", Value: 500, Timestamp: time.Now()},
+		{TraceID: "abc", Value: 50, Timestamp: time.Now()},
+	}
+	report := metrics.Report{
+		Counters:    map[string]uint64{"req.total": 3},
+		Gauges:      map[string]int64{"req.size": 2, "res.time.P99": 500, "gc.pause": 7},
+		FloatGauges: map[string]float64{"res.apdex": 0.5},
+		Histograms:  map[string]metrics.HistogramSnapshot{"res.time": snapshot},
+		TopK:        map[string][]metrics.TopKEntry{"req.top.paths": {{Item: "/foo", Count: 2}}},
+		Metadata: map[string]metrics.Metadata{
+			"res.time": {Kind: metrics.KindTimer, Unit: "ms", Description: "Response time"},
+			"gc.pause": {Kind: metrics.KindGauge},
+		},
+	}
+
+	req, err := reporter.mapReport(report, time.Now())
+	st.Expect(t, err, nil)
+	st.Expect(t, find(req, "res.time.P99"), (*metricspb.Metric)(nil))
+
+	sum := find(req, "req.total").GetSum()
+	st.Expect(t, sum.IsMonotonic, true)
+	st.Expect(t, sum.AggregationTemporality, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA)
+	st.Expect(t, find(req, "req.size").GetGauge().DataPoints[0].GetAsInt(), int64(2))
+	st.Expect(t, find(req, "res.apdex").GetGauge().DataPoints[0].GetAsDouble(), 0.5)
+	st.Expect(t, find(req, "gc.pause").GetGauge().DataPoints[0].GetAsInt(), int64(7))
+	st.Expect(t, find(req, "req.top.paths").GetGauge().DataPoints[0].Attributes[0].Value.GetStringValue(), "/foo")
+
+	metric := find(req, "res.time")
+	st.Expect(t, metric.Unit, "ms")
+	st.Expect(t, metric.Description, "Response time")
+	point := metric.GetHistogram().DataPoints[0]
+	st.Expect(t, point.Count, uint64(3))
+	st.Expect(t, point.ExplicitBounds, []float64{10, 100})
+	st.Expect(t, point.BucketCounts, []uint64{1, 1, 1})
+	st.Expect(t, len(point.Exemplars[0].TraceId), 16)
+	st.Expect(t, point.Exemplars[1].FilteredAttributes[0].Value.GetStringValue(), "abc")
+
+	report.Cumulative = true
+	req, _ = reporter.mapReport(report, time.Now())
+	st.Expect(t, find(req, "req.total").GetSum().AggregationTemporality, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE)
+	st.Expect(t, find(req, "req.total").GetSum().DataPoints[0].StartTimeUnixNano, uint64(reporter.start.UnixNano()))
+
+	// Start time is taken from the reported publish cycle start
+	report.Cumulative = false
+	report.Start = time.Unix(1000, 0)
+	req, _ = reporter.mapReport(report, time.Now())
+	st.Expect(t, find(req, "req.total").GetSum().DataPoints[0].StartTimeUnixNano, uint64(report.Start.UnixNano()))
+	st.Expect(t, find(req, "res.time").GetHistogram().DataPoints[0].StartTimeUnixNano, uint64(report.Start.UnixNano()))
+}
+
+func TestMapReportExponential(t *testing.T) {
+	reporter := New(Config{Exponential: true})
+	report := metrics.Report{Histograms: map[string]metrics.HistogramSnapshot{"res.time": metricstest.Histogram(0, 1, 2, 1000, 1000)}}
+
+	req, err := reporter.mapReport(report, time.Now())
+	st.Expect(t, err, nil)
+	point := find(req, "res.time").GetExponentialHistogram().DataPoints[0]
+	st.Expect(t, point.ZeroCount, uint64(1))
+	st.Expect(t, len(point.Positive.BucketCounts) <= MaxExponentialBuckets, true)
+
+	var total uint64
+	for _, count := range point.Positive.BucketCounts {
+		total += count
+	}
+	st.Expect(t, total, uint64(4))
+	st.Expect(t, point.Positive.BucketCounts[len(point.Positive.BucketCounts)-1], uint64(2))
+}
+
+func TestExponentialIndex(t *testing.T) {
+	st.Expect(t, exponentialIndex(1, 0), int32(-1))
+	st.Expect(t, exponentialIndex(2, 0), int32(0))
+	st.Expect(t, exponentialIndex(3, 0), int32(1))
+	st.Expect(t, exponentialIndex(4, 1), int32(3))
+}
@@ -0,0 +1,501 @@
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/vinxi/metrics.v0"
+	"gopkg.in/vinxi/metrics.v0/internal/keys"
+)
+
+// ContentType defines the Prometheus text exposition format content type.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// OpenMetricsContentType defines the OpenMetrics text format content type.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Config stores the Prometheus reporter settings.
+type Config struct {
+	// Namespace stores an optional prefix added to all the metric names.
+	Namespace string
+	// Labels stores constant labels added to all the series.
+	Labels map[string]string
+	// Mappings maps metric key prefixes to label names. Metrics whose key starts with
+	// a prefix are exposed under the prefix family with the remaining key as label value.
+	// E.g: {"res.status": "code"} exposes "res.status.200" as `res_status{code="200"}`.
+	Mappings map[string]string
+	// Summaries enables exposing histograms as summaries with quantiles instead of buckets.
+	Summaries bool
+	// Buckets stores the histogram buckets upper bounds. Defaults to metrics.Buckets.
+	Buckets []int64
+}
+
+// Reporter implements a Prometheus metrics reporter which exposes the reported metrics
+// in the Prometheus text exposition format via HTTP.
+//
+// Since counters and histograms are reset on every publish cycle, unless the meter
+// runs in cumulative mode, reporter accumulates them to expose monotonic series.
+type Reporter struct {
+	sync.Mutex
+	config     Config
+	counters   map[string]float64
+	gauges     map[string]float64
+	histograms map[string]metrics.HistogramSnapshot
+	latest     map[string]metrics.HistogramSnapshot
+	topk       map[string][]metrics.TopKEntry
+	metadata   map[string]metrics.Metadata
+	created    map[string]time.Time
+}
+
+// New creates a new Prometheus reporter.
+func New(c Config) *Reporter {
+	if c.Buckets == nil {
+		c.Buckets = metrics.Buckets
+	}
+	return &Reporter{
+		config:     c,
+		counters:   make(map[string]float64),
+		gauges:     make(map[string]float64),
+		histograms: make(map[string]metrics.HistogramSnapshot),
+		latest:     make(map[string]metrics.HistogramSnapshot),
+		topk:       make(map[string][]metrics.TopKEntry),
+		metadata:   make(map[string]metrics.Metadata),
+		created:    make(map[string]time.Time),
+	}
+}
+
+// Report implements the metrics.Reporter interface.
+func (r *Reporter) Report(re metrics.Report) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, key := range re.Expired {
+		r.expire(key)
+	}
+
+	// Track the creation time of the aggregated series
+	now := time.Now()
+	created := func(key string) {
+		if _, ok := r.created[key]; !ok {
+			r.created[key] = now
+		}
+	}
+	for key := range re.Counters {
+		created(key)
+	}
+	for key := range re.FloatCounters {
+		created(key)
+	}
+
+	// Accumulate counters, unless they are already cumulative
+	for key, value := range re.Counters {
+		r.count(key, float64(value), re.Cumulative)
+	}
+	for key, value := range re.FloatCounters {
+		r.count(key, value, re.Cumulative)
+	}
+
+	// Gauges only expose the latest values
+	r.gauges = make(map[string]float64)
+	for key, value := range re.Gauges {
+		if !re.IsHistogramPercentile(key) {
+			r.gauges[key] = float64(value)
+		}
+	}
+	for key, value := range re.FloatGauges {
+		r.gauges[key] = value
+	}
+
+	r.latest = make(map[string]metrics.HistogramSnapshot)
+	for key, snapshot := range re.Histograms {
+		created(key)
+		r.latest[key] = snapshot
+		if current, ok := r.histograms[key]; ok && !re.Cumulative {
+			merged, err := metrics.MergeHistograms(current, snapshot)
+			if err != nil {
+				return err
+			}
+			snapshot = merged
+		}
+		r.histograms[key] = snapshot
+	}
+
+	r.topk = re.TopK
+	r.metadata = re.Metadata
+	return nil
+}
+
+// ServeHTTP implements the http.Handler interface, exposing the metrics
+// in the OpenMetrics text format, if accepted by the client, or in the
+// Prometheus text exposition format otherwise.
+func (r *Reporter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	encode, contentType := r.Encode, ContentType
+	if strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text") {
+		encode, contentType = r.EncodeOpenMetrics, OpenMetricsContentType
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if err := encode(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Encode writes the metrics in the Prometheus text exposition format.
+func (r *Reporter) Encode(w io.Writer) error {
+	return r.encode(w, false)
+}
+
+// EncodeOpenMetrics writes the metrics in the OpenMetrics 1.0 text format,
+// including units, series creation timestamps and histogram exemplars.
+func (r *Reporter) EncodeOpenMetrics(w io.Writer) error {
+	return r.encode(w, true)
+}
+
+func (r *Reporter) encode(w io.Writer, om bool) error {
+	r.Lock()
+	defer r.Unlock()
+
+	buf := bufio.NewWriter(w)
+
+	// Series clashing with the histogram series names are skipped
+	reserved := r.reserved(om)
+
+	for _, g := range r.groups(keys.Sorted(r.counters)) {
+		name := r.family(g, "_total", om)
+		if reserved[name] || reserved[name+"_total"] {
+			continue
+		}
+		if om {
+			r.header(buf, name, g.meta, "counter", om)
+		} else {
+			r.header(buf, name+"_total", g.meta, "counter", om)
+		}
+		for _, s := range g.series {
+			r.sample(buf, name+"_total", r.counters[s.key], s.pairs...)
+			if om {
+				r.creation(buf, name, s)
+			}
+		}
+	}
+
+	for _, g := range r.groups(keys.Sorted(r.gauges)) {
+		name := r.family(g, "", om)
+		if reserved[name] {
+			continue
+		}
+		r.header(buf, name, g.meta, "gauge", om)
+		for _, s := range g.series {
+			r.sample(buf, name, r.gauges[s.key], s.pairs...)
+		}
+	}
+
+	for _, g := range r.groups(keys.Sorted(r.histograms)) {
+		name := r.family(g, "", om)
+		if r.config.Summaries {
+			r.header(buf, name, g.meta, "summary", om)
+		} else {
+			r.header(buf, name, g.meta, "histogram", om)
+		}
+		for _, s := range g.series {
+			if err := r.encodeHistogram(buf, name, s, om); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, g := range r.groups(keys.Sorted(r.topk)) {
+		name := r.family(g, "", om)
+		r.header(buf, name, g.meta, "gauge", om)
+		for _, s := range g.series {
+			for _, entry := range r.topk[s.key] {
+				r.sample(buf, name, float64(entry.Count), append(s.pairs, "item", entry.Item)...)
+			}
+		}
+	}
+
+	if om {
+		buf.WriteString("# EOF\n")
+	}
+	return buf.Flush()
+}
+
+func (r *Reporter) encodeHistogram(w io.Writer, name string, s series, om bool) error {
+	snapshot := r.histograms[s.key]
+	sum := snapshot.Mean * float64(snapshot.Count)
+
+	// Quantiles are only exposed for the histograms recorded in the last publish cycle
+	if r.config.Summaries {
+		if snapshot, ok := r.latest[s.key]; ok {
+			latest, err := metrics.DecodeHistogram(snapshot.Data)
+			if err != nil {
+				return err
+			}
+			for _, q := range sortedPercentiles() {
+				r.sample(w, name, float64(latest.ValueAtQuantile(q)), append(s.pairs, "quantile", formatFloat(q/100))...)
+			}
+		}
+	} else {
+		hist, err := metrics.DecodeHistogram(snapshot.Data)
+		if err != nil {
+			return err
+		}
+		counts := hist.CumulativeCounts(r.config.Buckets)
+		lower := int64(math.MinInt64)
+		for x, bound := range r.config.Buckets {
+			line := r.line(name+"_bucket", float64(counts[x]), append(s.pairs, "le", strconv.FormatInt(bound, 10))...)
+			if om {
+				line += exemplar(snapshot.Exemplars, lower, bound)
+			}
+			fmt.Fprintln(w, line)
+			lower = bound
+		}
+		line := r.line(name+"_bucket", float64(snapshot.Count), append(s.pairs, "le", "+Inf")...)
+		if om {
+			line += exemplar(snapshot.Exemplars, lower, math.MaxInt64)
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	r.sample(w, name+"_sum", sum, s.pairs...)
+	r.sample(w, name+"_count", float64(snapshot.Count), s.pairs...)
+	if om {
+		r.creation(w, name, s)
+	}
+	return nil
+}
+
+// header writes the HELP, TYPE and, for OpenMetrics, UNIT metric family lines.
+func (r *Reporter) header(w io.Writer, name, key, kind string, om bool) {
+	meta := r.metadata[key]
+	if meta.Description != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(meta.Description, om))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+	if om && meta.Unit != "" {
+		fmt.Fprintf(w, "# UNIT %s %s\n", name, sanitize(meta.Unit))
+	}
+}
+
+// sample writes a sample line with the constant labels and the given label pairs.
+func (r *Reporter) sample(w io.Writer, name string, value float64, pairs ...string) {
+	fmt.Fprintln(w, r.line(name, value, pairs...))
+}
+
+// creation writes the OpenMetrics series creation timestamp sample.
+func (r *Reporter) creation(w io.Writer, name string, s series) {
+	if created, ok := r.created[s.key]; ok {
+		fmt.Fprintf(w, "%s_created%s %s\n", name, r.labels(s.pairs...), timestamp(created))
+	}
+}
+
+// line formats a sample line with the constant labels and the given label pairs.
+func (r *Reporter) line(name string, value float64, pairs ...string) string {
+	return name + r.labels(pairs...) + " " + formatFloat(value)
+}
+
+// labels formats the constant labels and the given label pairs.
+func (r *Reporter) labels(pairs ...string) string {
+	var labels []string
+	for _, name := range keys.Sorted(r.config.Labels) {
+		labels = append(labels, fmt.Sprintf("%s=\"%s\"", sanitize(name), escapeLabel(r.config.Labels[name])))
+	}
+	for x := 0; x+1 < len(pairs); x += 2 {
+		labels = append(labels, fmt.Sprintf("%s=\"%s\"", pairs[x], escapeLabel(pairs[x+1])))
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// reserved returns the metric family and series names used by the histograms.
+func (r *Reporter) reserved(om bool) map[string]bool {
+	names := make(map[string]bool)
+	for _, g := range r.groups(keys.Sorted(r.histograms)) {
+		name := r.family(g, "", om)
+		for _, suffix := range []string{"", "_bucket", "_sum", "_count", "_created"} {
+			names[name+suffix] = true
+		}
+	}
+	return names
+}
+
+// family returns the metric family name for the given series group, without the given suffix.
+// OpenMetrics family names are suffixed by the metric unit, if any.
+func (r *Reporter) family(g group, suffix string, om bool) string {
+	name := strings.TrimSuffix(r.name(g.key), suffix)
+	if unit := sanitize(r.metadata[g.meta].Unit); om && unit != "" && !strings.HasSuffix(name, "_"+unit) {
+		name += "_" + unit
+	}
+	return name
+}
+
+// group stores the series exposed under the same metric family.
+type group struct {
+	// key stores the metric key the family name is derived from.
+	key string
+	// meta stores the metadata key describing the family.
+	meta string
+	// series stores the family series.
+	series []series
+}
+
+// series stores a metric key and the labels it is exposed with.
+type series struct {
+	key   string
+	pairs []string
+}
+
+// groups groups the given sorted metric keys by metric family, according to the label mappings.
+func (r *Reporter) groups(keys []string) []group {
+	var groups []group
+	index := make(map[string]int)
+	for _, key := range keys {
+		family, pairs := r.mapping(key)
+		x, ok := index[family]
+		if !ok {
+			meta := family
+			if _, ok := r.metadata[meta]; !ok {
+				meta = key
+			}
+			x = len(groups)
+			index[family] = x
+			groups = append(groups, group{key: family, meta: meta})
+		}
+		groups[x].series = append(groups[x].series, series{key: key, pairs: pairs})
+	}
+	return groups
+}
+
+// mapping returns the metric key and the label pair the given key is exposed with,
+// matching the longest label mapping prefix.
+func (r *Reporter) mapping(key string) (string, []string) {
+	var prefix string
+	for p := range r.config.Mappings {
+		if strings.HasPrefix(key, p+".") && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+	if prefix == "" {
+		return key, nil
+	}
+	return prefix, []string{sanitize(r.config.Mappings[prefix]), key[len(prefix)+1:]}
+}
+
+// name returns the sanitized metric name for the given key.
+func (r *Reporter) name(key string) string {
+	if r.config.Namespace != "" {
+		key = r.config.Namespace + "_" + key
+	}
+	return sanitize(key)
+}
+
+// count accumulates the given counter value.
+func (r *Reporter) count(key string, value float64, cumulative bool) {
+	if cumulative {
+		r.counters[key] = value
+		return
+	}
+	r.counters[key] += value
+}
+
+// expire removes the series identified by the given key and its derived series.
+func (r *Reporter) expire(key string) {
+	match := func(name string) bool {
+		return name == key || strings.HasPrefix(name, key+".")
+	}
+	for name := range r.counters {
+		if match(name) {
+			delete(r.counters, name)
+		}
+	}
+	for name := range r.gauges {
+		if match(name) {
+			delete(r.gauges, name)
+		}
+	}
+	for name := range r.histograms {
+		if match(name) {
+			delete(r.histograms, name)
+		}
+	}
+	for name := range r.created {
+		if match(name) {
+			delete(r.created, name)
+		}
+	}
+}
+
+// sanitize converts the given dotted metric key into a valid Prometheus metric name.
+func sanitize(key string) string {
+	name := []byte(key)
+	for x, c := range name {
+		valid := c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (x > 0 && c >= '0' && c <= '9')
+		if !valid {
+			name[x] = '_'
+		}
+	}
+	if len(name) > 0 && key[0] >= '0' && key[0] <= '9' {
+		return "_" + key[:1] + string(name[1:])
+	}
+	return string(name)
+}
+
+// exemplar formats the newest exemplar within the given bucket bounds, if any,
+// using the OpenMetrics exemplar syntax.
+func exemplar(exemplars []metrics.Exemplar, lower, upper int64) string {
+	var newest *metrics.Exemplar
+	for x, ex := range exemplars {
+		if ex.Value > lower && ex.Value <= upper && (newest == nil || ex.Timestamp.After(newest.Timestamp)) {
+			newest = &exemplars[x]
+		}
+	}
+	if newest == nil {
+		return ""
+	}
+	return fmt.Sprintf(" # {trace_id=\"%s\"} %d %s", escapeLabel(newest.TraceID), newest.Value, timestamp(newest.Timestamp))
+}
+
+func timestamp(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 3, 64)
+}
+
+func escapeHelp(s string, om bool) string {
+	if om {
+		return escapeLabel(s)
+	}
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedPercentiles() []float64 {
+	var values []float64
+	for _, q := range metrics.Percentiles {
+		values = append(values, q)
+	}
+	sort.Float64s(values)
+	return values
+}
@@ -0,0 +1,204 @@
+package prometheus
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbio/st"
+	"gopkg.in/vinxi/metrics.v0"
+	"gopkg.in/vinxi/metrics.v0/internal/metricstest"
+)
+
+func serve(t *testing.T, reporter *Reporter) string {
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	res := httptest.NewRecorder()
+	reporter.ServeHTTP(res, req)
+	st.Expect(t, res.Code, 200)
+	st.Expect(t, res.Header().Get("Content-Type"), ContentType)
+	return res.Body.String()
+}
+
+func TestReporterCounters(t *testing.T) {
+	reporter := New(Config{Namespace: "vinxi"})
+	report := metrics.Report{
+		Counters: map[string]uint64{"req.count": 10},
+		Metadata: map[string]metrics.Metadata{"req.count": {Kind: metrics.KindCounter, Description: "Total requests"}},
+	}
+	st.Expect(t, reporter.Report(report), nil)
+	st.Expect(t, reporter.Report(report), nil)
+
+	body := serve(t, reporter)
+	st.Expect(t, strings.Contains(body, "# HELP vinxi_req_count_total Total requests\n"), true)
+	st.Expect(t, strings.Contains(body, "# TYPE vinxi_req_count_total counter\n"), true)
+	st.Expect(t, strings.Contains(body, "vinxi_req_count_total 20\n"), true)
+
+	report.Cumulative = true
+	st.Expect(t, reporter.Report(report), nil)
+	st.Expect(t, strings.Contains(serve(t, reporter), "vinxi_req_count_total 10\n"), true)
+}
+
+func TestReporterGauges(t *testing.T) {
+	reporter := New(Config{Labels: map[string]string{"host": "a"}})
+	report := metrics.Report{
+		Gauges:      map[string]int64{"res.time.P99": 5, "req.size": 3, "gc.pause": 7},
+		FloatGauges: map[string]float64{"res.apdex": 0.5},
+		Histograms:  map[string]metrics.HistogramSnapshot{"res.time": metricstest.Histogram(1)},
+		Metadata:    map[string]metrics.Metadata{"gc.pause": {Kind: metrics.KindGauge}},
+	}
+	st.Expect(t, reporter.Report(report), nil)
+
+	body := serve(t, reporter)
+	st.Expect(t, strings.Contains(body, "req_size{host=\"a\"} 3\n"), true)
+	st.Expect(t, strings.Contains(body, "res_apdex{host=\"a\"} 0.5\n"), true)
+	st.Expect(t, strings.Contains(body, "# TYPE gc_pause gauge\ngc_pause{host=\"a\"} 7\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_P99"), false)
+}
+
+func TestReporterMappings(t *testing.T) {
+	reporter := New(Config{Mappings: map[string]string{"res.status": "code", "res.time.path": "path"}, Buckets: []int64{10}})
+	report := metrics.Report{
+		Counters:   map[string]uint64{"res.status.200": 3, "res.status.404": 1, "req.count": 4},
+		Histograms: map[string]metrics.HistogramSnapshot{"res.time.path./foo": metricstest.Histogram(5), "res.time.path./bar": metricstest.Histogram(50)},
+		Metadata:   map[string]metrics.Metadata{"res.status": {Kind: metrics.KindCounter, Description: "Responses by status"}},
+	}
+	st.Expect(t, reporter.Report(report), nil)
+
+	body := serve(t, reporter)
+	st.Expect(t, strings.Count(body, "# TYPE res_status_total counter\n"), 1)
+	st.Expect(t, strings.Contains(body, "# HELP res_status_total Responses by status\n"), true)
+	st.Expect(t, strings.Contains(body, "res_status_total{code=\"200\"} 3\nres_status_total{code=\"404\"} 1\n"), true)
+	st.Expect(t, strings.Contains(body, "req_count_total 4\n"), true)
+	st.Expect(t, strings.Count(body, "# TYPE res_time_path histogram\n"), 1)
+	st.Expect(t, strings.Contains(body, "res_time_path_bucket{path=\"/bar\",le=\"10\"} 0\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_path_bucket{path=\"/foo\",le=\"10\"} 1\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_path_count{path=\"/foo\"} 1\n"), true)
+}
+
+func TestReporterHistograms(t *testing.T) {
+	reporter := New(Config{Buckets: []int64{10, 100}})
+	report := metrics.Report{Histograms: map[string]metrics.HistogramSnapshot{"res.time": metricstest.Histogram(5, 50, 500)}}
+	st.Expect(t, reporter.Report(report), nil)
+	st.Expect(t, reporter.Report(report), nil)
+
+	body := serve(t, reporter)
+	st.Expect(t, strings.Contains(body, "# TYPE res_time histogram\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_bucket{le=\"10\"} 2\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_bucket{le=\"100\"} 4\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_bucket{le=\"+Inf\"} 6\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_count 6\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_sum "), true)
+}
+
+func TestReporterSummaries(t *testing.T) {
+	reporter := New(Config{Summaries: true})
+	report := metrics.Report{Histograms: map[string]metrics.HistogramSnapshot{"res.time": metricstest.Histogram(5)}}
+	st.Expect(t, reporter.Report(report), nil)
+
+	body := serve(t, reporter)
+	st.Expect(t, strings.Contains(body, "# TYPE res_time summary\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time{quantile=\"0.99\"} 5\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_count 1\n"), true)
+
+	// Idle publish cycles expose the accumulated series without quantiles
+	st.Expect(t, reporter.Report(metrics.Report{}), nil)
+	body = serve(t, reporter)
+	st.Expect(t, strings.Contains(body, "quantile"), false)
+	st.Expect(t, strings.Contains(body, "res_time_count 1\n"), true)
+}
+
+func TestReporterTopKAndExpiry(t *testing.T) {
+	reporter := New(Config{})
+	report := metrics.Report{
+		Counters: map[string]uint64{"req.path./foo": 1},
+		TopK:     map[string][]metrics.TopKEntry{"req.top.paths": {{Item: "/foo\"", Count: 3}}},
+	}
+	st.Expect(t, reporter.Report(report), nil)
+	body := serve(t, reporter)
+	st.Expect(t, strings.Contains(body, "req_top_paths{item=\"/foo\\\"\"} 3\n"), true)
+	st.Expect(t, strings.Contains(body, "req_path__foo_total 1\n"), true)
+
+	st.Expect(t, reporter.Report(metrics.Report{Expired: []string{"req.path"}}), nil)
+	st.Expect(t, strings.Contains(serve(t, reporter), "req_path__foo_total"), false)
+}
+
+func TestSanitize(t *testing.T) {
+	st.Expect(t, sanitize("res.status.2xx"), "res_status_2xx")
+	st.Expect(t, sanitize("5xx.errors"), "_5xx_errors")
+	st.Expect(t, sanitize("a-b:c"), "a_b:c")
+}
+
+func TestReporterOpenMetrics(t *testing.T) {
+	reporter := New(Config{Buckets: []int64{10, 100}})
+	snapshot := metricstest.Histogram(5, 50)
+	snapshot.Exemplars = []metrics.Exemplar{{TraceID: "abc", Value: 50, Timestamp: time.Unix(1, 0)}}
+	report := metrics.Report{
+		Counters:   map[string]uint64{"req.count": 3},
+		Histograms: map[string]metrics.HistogramSnapshot{"res.time": snapshot},
+		Metadata: map[string]metrics.Metadata{
+			"req.count": {Kind: metrics.KindCounter, Description: "Total \"requests\""},
+			"res.time":  {Kind: metrics.KindTimer, Unit: "ms"},
+		},
+	}
+	st.Expect(t, reporter.Report(report), nil)
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0,text/plain;q=0.5")
+	res := httptest.NewRecorder()
+	reporter.ServeHTTP(res, req)
+	st.Expect(t, res.Header().Get("Content-Type"), OpenMetricsContentType)
+
+	body := res.Body.String()
+	st.Expect(t, strings.Contains(body, "# HELP req_count Total \\\"requests\\\"\n# TYPE req_count counter\nreq_count_total 3\nreq_count_created "), true)
+	st.Expect(t, strings.Contains(body, "# TYPE res_time_ms histogram\n# UNIT res_time_ms ms\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_ms_bucket{le=\"10\"} 1\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_ms_bucket{le=\"100\"} 2 # {trace_id=\"abc\"} 50 1.000\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_ms_created "), true)
+	st.Expect(t, strings.HasSuffix(body, "# EOF\n"), true)
+
+	classic := serve(t, reporter)
+	st.Expect(t, strings.Contains(classic, "# EOF"), false)
+	st.Expect(t, strings.Contains(classic, "trace_id"), false)
+	st.Expect(t, strings.Contains(classic, "res_time_bucket{le=\"100\"} 2\n"), true)
+}
+
+func TestReporterOpenMetricsTimer(t *testing.T) {
+	registry := metrics.NewMetrics()
+	registry.Timer("res.time").Record(5 * time.Millisecond)
+	registry.Timer("res.time").Record(50 * time.Millisecond)
+	report := registry.Snapshot()
+	report.Counters["req.total"] = 2
+	report.Histograms["db.time"] = metricstest.Histogram(5)
+	report.Counters["db.time.count"] = 1
+	report.Metadata["req.total"] = metrics.Metadata{Kind: metrics.KindCounter, Unit: "requests"}
+
+	reporter := New(Config{Buckets: []int64{10, 100}})
+	st.Expect(t, reporter.Report(report), nil)
+
+	var buf bytes.Buffer
+	st.Expect(t, reporter.EncodeOpenMetrics(&buf), nil)
+	body := buf.String()
+
+	// Every metric family is declared once
+	families := make(map[string]bool)
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			name := strings.Fields(line)[2]
+			st.Expect(t, families[name], false)
+			families[name] = true
+		}
+	}
+
+	st.Expect(t, strings.Contains(body, "# TYPE res_time_ms histogram\n"), true)
+	st.Expect(t, strings.Contains(body, "res_time_ms_count 2\n"), true)
+	st.Expect(t, strings.Contains(body, "# TYPE res_time_count counter\nres_time_count_total 2\n"), true)
+	st.Expect(t, strings.Contains(body, "# TYPE req_requests counter\n"), true)
+	st.Expect(t, strings.Contains(body, "req_requests_total 2\n"), true)
+
+	// Counters clashing with the histogram series are skipped
+	st.Expect(t, strings.Contains(body, "db_time_count 1\n"), true)
+	st.Expect(t, strings.Contains(body, "db_time_count_total"), false)
+}
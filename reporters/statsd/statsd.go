@@ -0,0 +1,328 @@
+package statsd
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/vinxi/metrics.v0"
+	"gopkg.in/vinxi/metrics.v0/internal/keys"
+)
+
+// DefaultAddress defines the default StatsD server address.
+var DefaultAddress = "localhost:8125"
+
+// MaxPacketSize defines the default maximum UDP datagram payload size in bytes,
+// which fits into a typical Ethernet MTU without fragmentation.
+var MaxPacketSize = 1432
+
+// MaxUnixPacketSize defines the default maximum Unix domain socket datagram payload size in bytes.
+var MaxUnixPacketSize = 8192
+
+// UnixPrefix defines the address prefix used to send datagrams via Unix domain socket.
+const UnixPrefix = "unix://"
+
+// ErrCumulative is returned when reporting cumulative metrics, since StatsD servers
+// aggregate the received values themselves, which would count them multiple times.
+var ErrCumulative = errors.New("statsd: cumulative reports are not supported")
+
+// Config stores the StatsD reporter settings.
+type Config struct {
+	// Address stores the StatsD server UDP address. Defaults to DefaultAddress.
+	// Addresses prefixed by UnixPrefix use Unix domain socket transport, such as "unix:///var/run/datadog/dsd.socket".
+	Address string
+	// Prefix stores an optional prefix added to all the metric names.
+	Prefix string
+	// SampleRate stores the sample rate, between 0 and 1, applied to raw timings and distributions.
+	// Counters are aggregated per publish cycle, so they are never sampled.
+	// Defaults to 1, which means no sampling.
+	SampleRate float64
+	// Timings enables sending histograms as raw timings (|ms) instead of percentile gauges.
+	Timings bool
+	// MaxPacketSize stores the maximum datagram payload size.
+	// Defaults to MaxPacketSize, or MaxUnixPacketSize for Unix domain sockets.
+	MaxPacketSize int
+	// DogStatsD enables the DogStatsD protocol extensions, such as tags.
+	DogStatsD bool
+	// Distributions enables sending histograms as DogStatsD distributions (|d).
+	Distributions bool
+	// Tags stores the DogStatsD tags added to all the metrics.
+	Tags map[string]string
+	// Labels maps metric key prefixes to DogStatsD tag names. Metrics whose key starts
+	// with a prefix are sent with the prefix as name and the remaining key as tag value.
+	// E.g: {"res.status": "status"} sends "res.status.200" as "res.status" tagged by "status:200".
+	Labels map[string]string
+}
+
+// Reporter implements a StatsD metrics reporter which sends data to a StatsD server via UDP.
+type Reporter struct {
+	sync.Mutex
+	config Config
+	conn   net.Conn
+}
+
+// New creates a new StatsD reporter which will send the metrics to the specified server.
+func New(c Config) *Reporter {
+	if c.Address == "" {
+		c.Address = DefaultAddress
+	}
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		c.SampleRate = 1
+	}
+	if c.MaxPacketSize <= 0 {
+		c.MaxPacketSize = MaxPacketSize
+		if strings.HasPrefix(c.Address, UnixPrefix) {
+			c.MaxPacketSize = MaxUnixPacketSize
+		}
+	}
+	return &Reporter{config: c}
+}
+
+// NewDogStatsD creates a new DogStatsD reporter which will send the metrics to the specified Datadog agent.
+func NewDogStatsD(c Config) *Reporter {
+	c.DogStatsD = true
+	return New(c)
+}
+
+// Report implements the metrics.Reporter interface.
+func (r *Reporter) Report(re metrics.Report) error {
+	lines, err := r.mapReport(re)
+	if err == nil {
+		err = r.send(lines)
+	}
+	if err != nil {
+		log.Printf("statsd: error sending metrics err=%v", err)
+	}
+	return err
+}
+
+// send packs the given lines into datagrams up to the maximum packet size and writes them.
+func (r *Reporter) send(lines []string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.conn == nil {
+		conn, err := dial(r.config.Address)
+		if err != nil {
+			return err
+		}
+		r.conn = conn
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		if buf.Len() > 0 && buf.Len()+len(line)+1 > r.config.MaxPacketSize {
+			if err := r.write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+
+	if buf.Len() > 0 {
+		return r.write(buf.Bytes())
+	}
+	return nil
+}
+
+// write writes the given datagram, closing the connection on failure,
+// so it is dialed again in the next publish cycle. Caller must hold the lock.
+func (r *Reporter) write(data []byte) error {
+	_, err := r.conn.Write(data)
+	if err != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+	return err
+}
+
+// dial connects to the given UDP or Unix domain socket address.
+func dial(address string) (net.Conn, error) {
+	if strings.HasPrefix(address, UnixPrefix) {
+		return net.Dial("unixgram", strings.TrimPrefix(address, UnixPrefix))
+	}
+	return net.Dial("udp", address)
+}
+
+// Close closes the underlying connection, if any.
+func (r *Reporter) Close() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+func (r *Reporter) mapReport(re metrics.Report) ([]string, error) {
+	if re.Cumulative {
+		return nil, ErrCumulative
+	}
+
+	var lines []string
+
+	// Add counters
+	for _, key := range keys.Sorted(re.Counters) {
+		lines = append(lines, r.line(key, strconv.FormatUint(re.Counters[key], 10), "c", 1))
+	}
+	for _, key := range keys.Sorted(re.FloatCounters) {
+		lines = append(lines, r.line(key, formatFloat(re.FloatCounters[key]), "c", 1))
+	}
+
+	// Add gauges, skipping the histogram percentiles when sending raw timings
+	for _, key := range keys.Sorted(re.Gauges) {
+		if (r.config.Timings || r.config.Distributions) && re.IsHistogramPercentile(key) {
+			continue
+		}
+		lines = r.gauge(lines, key, float64(re.Gauges[key]))
+	}
+	for _, key := range keys.Sorted(re.FloatGauges) {
+		lines = r.gauge(lines, key, re.FloatGauges[key])
+	}
+
+	// Add top-k entries as tagged gauges
+	if r.config.DogStatsD {
+		for _, key := range keys.Sorted(re.TopK) {
+			for _, entry := range re.TopK[key] {
+				line := r.line(key, strconv.FormatUint(entry.Count, 10), "g", 1)
+				lines = append(lines, r.tag(line, "item:"+sanitizeTag(entry.Item)))
+			}
+		}
+	}
+
+	kind := "ms"
+	if r.config.Distributions {
+		kind = "d"
+	}
+	if !r.config.Timings && !r.config.Distributions {
+		return lines, nil
+	}
+
+	// Add histograms as raw timings or distributions
+	for _, key := range keys.Sorted(re.Histograms) {
+		snapshot := re.Histograms[key]
+
+		hist, err := metrics.DecodeHistogram(snapshot.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		// Every recorded value is sent according to the sample rate
+		for _, value := range hist.Values() {
+			for x := int64(0); x < value.Count; x++ {
+				lines = r.sampled(lines, key, strconv.FormatInt(value.Value, 10), kind)
+			}
+		}
+	}
+
+	return lines, nil
+}
+
+// gauge appends a gauge line. Negative gauges are reset to zero first,
+// since StatsD interprets signed gauge values as relative changes.
+func (r *Reporter) gauge(lines []string, key string, value float64) []string {
+	if value < 0 {
+		lines = append(lines, r.line(key, "0", "g", 1))
+	}
+	return append(lines, r.line(key, formatFloat(value), "g", 1))
+}
+
+// sampled appends a raw value line according to the configured sample rate.
+func (r *Reporter) sampled(lines []string, key, value, kind string) []string {
+	if r.config.SampleRate < 1 && rand.Float64() >= r.config.SampleRate {
+		return lines
+	}
+	return append(lines, r.line(key, value, kind, r.config.SampleRate))
+}
+
+// line formats a StatsD metric line, including the DogStatsD tags, if enabled.
+func (r *Reporter) line(key, value, kind string, rate float64) string {
+	var tags []string
+	if r.config.DogStatsD {
+		key, tags = r.tags(key)
+	}
+
+	line := r.name(key) + ":" + value + "|" + kind
+	if rate < 1 {
+		line += "|@" + formatFloat(rate)
+	}
+	return r.tag(line, tags...)
+}
+
+// tags returns the metric key without labels and the DogStatsD tags for the given key.
+func (r *Reporter) tags(key string) (string, []string) {
+	var tags []string
+	for _, name := range keys.Sorted(r.config.Tags) {
+		tags = append(tags, sanitizeTag(name)+":"+sanitizeTag(r.config.Tags[name]))
+	}
+
+	// Extract the label by the longest matching key prefix
+	prefix := ""
+	for p := range r.config.Labels {
+		if strings.HasPrefix(key, p+".") && len(p) > len(prefix) {
+			prefix = p
+		}
+	}
+	if prefix != "" {
+		tags = append(tags, sanitizeTag(r.config.Labels[prefix])+":"+sanitizeTag(key[len(prefix)+1:]))
+		key = prefix
+	}
+
+	return key, tags
+}
+
+// tag appends the given DogStatsD tags to the line.
+func (r *Reporter) tag(line string, tags ...string) string {
+	if len(tags) == 0 {
+		return line
+	}
+	if strings.Contains(line, "|#") {
+		return line + "," + strings.Join(tags, ",")
+	}
+	return line + "|#" + strings.Join(tags, ",")
+}
+
+// name returns the prefixed and sanitized metric name for the given key.
+func (r *Reporter) name(key string) string {
+	if r.config.Prefix != "" {
+		key = strings.TrimSuffix(r.config.Prefix, ".") + "." + key
+	}
+	return sanitize(key)
+}
+
+// sanitize replaces the characters reserved by the StatsD protocol.
+func sanitize(key string) string {
+	return strings.Map(func(c rune) rune {
+		switch c {
+		case ':', '|', '@', '#', ',', ' ', '\n', '\t':
+			return '_'
+		}
+		return c
+	}, key)
+}
+
+// sanitizeTag replaces the characters reserved by the DogStatsD tags format.
+func sanitizeTag(tag string) string {
+	return strings.Map(func(c rune) rune {
+		switch c {
+		case '|', ',', '#', ' ', '\n', '\t':
+			return '_'
+		}
+		return c
+	}, tag)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
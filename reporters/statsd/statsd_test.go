@@ -0,0 +1,182 @@
+package statsd
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbio/st"
+	"gopkg.in/vinxi/metrics.v0"
+	"gopkg.in/vinxi/metrics.v0/internal/metricstest"
+)
+
+func listen(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	st.Assert(t, err, nil)
+	return conn
+}
+
+func read(t *testing.T, conn net.PacketConn) string {
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	st.Assert(t, err, nil)
+	return string(buf[:n])
+}
+
+func TestReport(t *testing.T) {
+	conn := listen(t)
+	defer conn.Close()
+
+	reporter := New(Config{Address: conn.LocalAddr().String(), Prefix: "vinxi"})
+	defer reporter.Close()
+
+	report := metrics.Report{
+		Counters:    map[string]uint64{"req.total": 10},
+		Gauges:      map[string]int64{"req.size": -3, "res.time.P99": 5},
+		FloatGauges: map[string]float64{"res.apdex": 0.5},
+		Histograms:  map[string]metrics.HistogramSnapshot{"res.time": metricstest.Histogram(5)},
+	}
+	st.Expect(t, reporter.Report(report), nil)
+	st.Expect(t, read(t, conn), strings.Join([]string{
+		"vinxi.req.total:10|c",
+		"vinxi.req.size:0|g",
+		"vinxi.req.size:-3|g",
+		"vinxi.res.time.P99:5|g",
+		"vinxi.res.apdex:0.5|g",
+	}, "\n"))
+}
+
+func TestReportTimings(t *testing.T) {
+	reporter := New(Config{Timings: true})
+	report := metrics.Report{
+		Gauges:     map[string]int64{"res.time.P99": 10, "gc.pause": 7},
+		Histograms: map[string]metrics.HistogramSnapshot{"res.time": metricstest.Histogram(5, 10, 10, 10, 10)},
+		Metadata:   map[string]metrics.Metadata{"gc.pause": {Kind: metrics.KindGauge}},
+	}
+	lines, err := reporter.mapReport(report)
+	st.Expect(t, err, nil)
+	st.Expect(t, lines, []string{
+		"gc.pause:7|g",
+		"res.time:5|ms",
+		"res.time:10|ms",
+		"res.time:10|ms",
+		"res.time:10|ms",
+		"res.time:10|ms",
+	})
+}
+
+func TestReportCumulative(t *testing.T) {
+	reporter := New(Config{})
+	_, err := reporter.mapReport(metrics.Report{Counters: map[string]uint64{"req.total": 10}, Cumulative: true})
+	st.Expect(t, err, ErrCumulative)
+}
+
+func TestReportPackets(t *testing.T) {
+	conn := listen(t)
+	defer conn.Close()
+
+	reporter := New(Config{Address: conn.LocalAddr().String(), MaxPacketSize: 20})
+	defer reporter.Close()
+
+	st.Expect(t, reporter.send([]string{"foo:1|c", "bar:1|c", "baz:1|c"}), nil)
+	st.Expect(t, read(t, conn), "foo:1|c\nbar:1|c")
+	st.Expect(t, read(t, conn), "baz:1|c")
+}
+
+func TestSampleRate(t *testing.T) {
+	reporter := New(Config{SampleRate: 0.5, Timings: true})
+	var lines []string
+	for x := 0; x < 1000; x++ {
+		lines = reporter.sampled(lines, "foo", "1", "ms")
+	}
+	st.Expect(t, len(lines) > 0 && len(lines) < 1000, true)
+	st.Expect(t, lines[0], "foo:1|ms|@0.5")
+
+	// Aggregated counters are never sampled
+	report := metrics.Report{Counters: map[string]uint64{"req.total": 10}}
+	for x := 0; x < 100; x++ {
+		lines, err := reporter.mapReport(report)
+		st.Expect(t, err, nil)
+		st.Expect(t, lines, []string{"req.total:10|c"})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	st.Expect(t, sanitize("req.host.a:80|b"), "req.host.a_80_b")
+}
+
+func TestDogStatsD(t *testing.T) {
+	reporter := NewDogStatsD(Config{
+		Tags:          map[string]string{"env": "prod", "app": "vinxi"},
+		Labels:        map[string]string{"res.status": "status", "res": "kind"},
+		Distributions: true,
+	})
+	report := metrics.Report{
+		Counters:   map[string]uint64{"res.status.200": 3, "req.total": 1},
+		Histograms: map[string]metrics.HistogramSnapshot{"res.time": metricstest.Histogram(5)},
+		Gauges:     map[string]int64{"res.time.P99": 5},
+		TopK:       map[string][]metrics.TopKEntry{"req.top.paths": {{Item: "/a,b", Count: 2}}},
+	}
+	lines, err := reporter.mapReport(report)
+	st.Expect(t, err, nil)
+	st.Expect(t, lines, []string{
+		"req.total:1|c|#app:vinxi,env:prod",
+		"res.status:3|c|#app:vinxi,env:prod,status:200",
+		"req.top.paths:2|g|#app:vinxi,env:prod,item:/a_b",
+		"res:5|d|#app:vinxi,env:prod,kind:time",
+	})
+}
+
+func TestDogStatsDUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	st.Assert(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dsd.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	st.Assert(t, err, nil)
+	defer conn.Close()
+
+	reporter := NewDogStatsD(Config{Address: UnixPrefix + path})
+	defer reporter.Close()
+	st.Expect(t, reporter.config.MaxPacketSize, MaxUnixPacketSize)
+
+	report := metrics.Report{Counters: map[string]uint64{"req.total": 1}}
+	st.Expect(t, reporter.Report(report), nil)
+	st.Expect(t, read(t, conn), "req.total:1|c")
+}
+
+func TestReconnect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	st.Assert(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dsd.socket")
+	addr := &net.UnixAddr{Name: path, Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	st.Assert(t, err, nil)
+
+	reporter := New(Config{Address: UnixPrefix + path})
+	defer reporter.Close()
+
+	report := metrics.Report{Counters: map[string]uint64{"req.total": 1}}
+	st.Expect(t, reporter.Report(report), nil)
+	st.Expect(t, read(t, conn), "req.total:1|c")
+
+	// Restart the listener
+	conn.Close()
+	os.Remove(path)
+	st.Expect(t, reporter.Report(report) != nil, true)
+	st.Expect(t, reporter.conn, nil)
+
+	conn, err = net.ListenUnixgram("unixgram", addr)
+	st.Assert(t, err, nil)
+	defer conn.Close()
+	st.Expect(t, reporter.Report(report), nil)
+	st.Expect(t, read(t, conn), "req.total:1|c")
+}
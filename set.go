@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// SetPrecision defines the default HyperLogLog precision used by Set metrics.
+// Sets use 2^precision bytes of memory, with a standard error of 1.04/sqrt(2^precision).
+// Defaults to 14 (16 KB, ~0.81% standard error).
+var SetPrecision uint8 = 14
+
+// Set implements a distinct count metric backed by a HyperLogLog sketch,
+// which estimates the number of unique values using a fixed amount of memory.
+//
+// Set is designed to be safety used by multiple goroutines.
+type Set struct {
+	sync.Mutex
+	precision uint8
+	registers []uint8
+}
+
+// NewSet creates a new distinct count set with the given precision, between 4 and 18.
+func NewSet(precision uint8) *Set {
+	if precision < 4 {
+		precision = 4
+	}
+	if precision > 18 {
+		precision = 18
+	}
+	return &Set{precision: precision, registers: make([]uint8, 1<<precision)}
+}
+
+// Add adds the given value to the set.
+func (s *Set) Add(value string) {
+	hash := hashString(value)
+	index := hash >> (64 - s.precision)
+	rank := uint8(bits.LeadingZeros64(hash<<s.precision|1<<(s.precision-1))) + 1
+
+	s.Lock()
+	if rank > s.registers[index] {
+		s.registers[index] = rank
+	}
+	s.Unlock()
+}
+
+// Count returns the estimated number of unique values in the set.
+func (s *Set) Count() uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	size := float64(len(s.registers))
+	sum, zeros := 0.0, 0
+	for _, rank := range s.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha(size) * size * size / sum
+	// Use linear counting for small cardinalities
+	if estimate <= 2.5*size && zeros > 0 {
+		estimate = size * math.Log(size/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// alpha returns the HyperLogLog bias correction constant for the given number of registers.
+func alpha(size float64) float64 {
+	switch size {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/size)
+}
+
+// hashString returns a well distributed 64 bits hash of the given string.
+func hashString(value string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	// Apply the murmur3 finalizer to improve the bits avalanche
+	hash := h.Sum64()
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
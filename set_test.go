@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func TestSet(t *testing.T) {
+	set := NewSet(14)
+	st.Expect(t, set.Count(), uint64(0))
+
+	for x := 0; x < 3; x++ {
+		set.Add("foo")
+		set.Add("bar")
+	}
+	st.Expect(t, set.Count(), uint64(2))
+
+	for x := 0; x < 100000; x++ {
+		set.Add(fmt.Sprintf("client-%d", x))
+	}
+	count := float64(set.Count())
+	st.Expect(t, count > 98000 && count < 102000, true)
+}
+
+func TestMeterUniqueClients(t *testing.T) {
+	info, metrics := createMetrics()
+	defer metrics.Reset()
+
+	info.Request.RemoteAddr = "10.0.0.1:1234"
+	MeterUniqueClients(info, metrics)
+	MeterUniqueClients(info, metrics)
+
+	info.Request.Header = make(map[string][]string)
+	info.Request.Header.Set("X-Forwarded-For", "10.0.0.2, 10.0.0.1")
+	MeterUniqueClients(info, metrics)
+	st.Expect(t, metrics.Snapshot().Gauges["req.clients"], int64(2))
+}
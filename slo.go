@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SLOPeriod defines the default SLO compliance period used to calculate the error budget.
+// Defaults to 30 days.
+var SLOPeriod = 30 * 24 * time.Hour
+
+// SLOWindows defines the rolling windows used to calculate the SLO burn rates.
+// Defaults to 5 minutes, 1 hour, 6 hours and 3 days.
+var SLOWindows = []time.Duration{
+	5 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	3 * 24 * time.Hour,
+}
+
+// SLO represents a service level objective declared against the HTTP traffic seen by Meter.
+// SLO tracks the good and total events across multiple rolling windows, and publishes
+// the following float gauges every publish cycle:
+//
+//	slo.<name>.sli - ratio of good events over the compliance period.
+//	slo.<name>.budget - remaining error budget over the compliance period.
+//	slo.<name>.burn_rate.<window> - error budget burn rate per rolling window (e.g: 5m, 1h, 6h, 3d).
+//
+// SLO is designed to be safety used by multiple goroutines.
+type SLO struct {
+	// Mutex provides synchronization for thead safety.
+	sync.Mutex
+
+	// Name stores the SLO name used to compose the metric keys.
+	Name string
+
+	// Objective stores the target ratio of good events, e.g: 0.999.
+	Objective float64
+
+	// Latency defines the maximum response time for an event to be considered good.
+	// Zero means no latency requirement.
+	Latency time.Duration
+
+	// Period stores the compliance period used to calculate the error budget.
+	// Defaults to SLOPeriod.
+	Period time.Duration
+
+	// Windows stores the rolling windows used to calculate the burn rates.
+	// Defaults to SLOWindows.
+	Windows []time.Duration
+
+	// Methods optionally restricts the SLO to the given HTTP methods.
+	Methods []string
+
+	// Filter optionally restricts the SLO to the events matched by the given function.
+	Filter func(*Info) bool
+
+	// period stores the good/total events over the compliance period.
+	period *rollingCounter
+	// windows stores the good/total events per burn rate window.
+	windows []*rollingCounter
+}
+
+// NewSLO creates a new SLO with the given name and objective ratio. It will also set
+// the values of the exported fields to the described defaults. The values of the
+// exported fields can be changed at any point before the first event is recorded.
+func NewSLO(name string, objective float64) *SLO {
+	return &SLO{
+		Name:      name,
+		Objective: objective,
+		Period:    SLOPeriod,
+		Windows:   SLOWindows,
+	}
+}
+
+// Match returns true if the given request info is covered by the SLO.
+func (s *SLO) Match(i *Info) bool {
+	if len(s.Methods) > 0 && !matchMethod(i.Request.Method, s.Methods) {
+		return false
+	}
+	return s.Filter == nil || s.Filter(i)
+}
+
+// Good returns true if the given request info is considered a good event.
+// Failed responses are always considered as bad events.
+func (s *SLO) Good(i *Info) bool {
+	if i.Status >= 500 {
+		return false
+	}
+	return s.Latency == 0 || i.TimeEnd.Sub(i.TimeStart) <= s.Latency
+}
+
+// Record registers a new event, if covered by the SLO.
+func (s *SLO) Record(i *Info) {
+	if !s.Match(i) {
+		return
+	}
+
+	good := s.Good(i)
+	s.Lock()
+	s.init()
+	s.period.add(i.TimeEnd, good)
+	for _, window := range s.windows {
+		window.add(i.TimeEnd, good)
+	}
+	s.Unlock()
+}
+
+// BurnRate returns the error budget burn rate over the given rolling window.
+// A burn rate of 1 means the error budget would be exactly consumed at the end of the period.
+func (s *SLO) BurnRate(window time.Duration, now time.Time) float64 {
+	s.Lock()
+	defer s.Unlock()
+	s.init()
+	for x, w := range s.Windows {
+		if w == window {
+			return s.burnRate(s.windows[x].sum(now))
+		}
+	}
+	return 0
+}
+
+// Budget returns the remaining error budget ratio over the compliance period.
+// A negative value means the error budget has been exhausted.
+func (s *SLO) Budget(now time.Time) float64 {
+	s.Lock()
+	defer s.Unlock()
+	s.init()
+	return 1 - s.burnRate(s.period.sum(now))
+}
+
+// Publish writes the SLO gauges into the given metrics.
+func (s *SLO) Publish(m *Metrics, now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	s.init()
+
+	key := "slo." + s.Name
+	good, total := s.period.sum(now)
+	if total > 0 {
+		m.FloatGauge(key + ".sli").Set(float64(good) / float64(total))
+		m.Describe(key+".sli", Metadata{Kind: KindGauge, Description: "Ratio of good events over the SLO period"})
+	}
+	m.FloatGauge(key + ".budget").Set(1 - s.burnRate(good, total))
+	m.Describe(key+".budget", Metadata{Kind: KindGauge, Description: "Remaining error budget ratio over the SLO period"})
+
+	for x, window := range s.windows {
+		name := fmt.Sprintf("%s.burn_rate.%s", key, formatWindow(s.Windows[x]))
+		m.FloatGauge(name).Set(s.burnRate(window.sum(now)))
+		m.Describe(name, Metadata{Kind: KindGauge, Description: "Error budget burn rate over the last " + formatWindow(s.Windows[x])})
+	}
+}
+
+// init lazily initializes the rolling counters. Caller must hold the lock.
+func (s *SLO) init() {
+	if s.period != nil {
+		return
+	}
+	s.period = newRollingCounter(s.Period, 720)
+	for _, window := range s.Windows {
+		s.windows = append(s.windows, newRollingCounter(window, 60))
+	}
+}
+
+// burnRate calculates the burn rate for the given good and total events.
+func (s *SLO) burnRate(good, total uint64) float64 {
+	if total == 0 || s.Objective >= 1 {
+		return 0
+	}
+	bad := float64(total-good) / float64(total)
+	return bad / (1 - s.Objective)
+}
+
+// rollingCounter implements a time bucketed counter of good and total events
+// over a rolling window. Caller is responsible of synchronization.
+type rollingCounter struct {
+	ring    ring
+	buckets []rollingBucket
+}
+
+type rollingBucket struct {
+	good  uint64
+	total uint64
+}
+
+// newRollingCounter creates a new rolling counter for the given window split into size buckets.
+func newRollingCounter(window time.Duration, size int) *rollingCounter {
+	return &rollingCounter{ring: newRing(window, size), buckets: make([]rollingBucket, size)}
+}
+
+// add registers a new event at the given time.
+func (r *rollingCounter) add(t time.Time, good bool) {
+	bucket := &r.buckets[r.ring.advance(t, r.clear)]
+	bucket.total++
+	if good {
+		bucket.good++
+	}
+}
+
+// sum returns the total number of good and total events in the window ending at the given time.
+func (r *rollingCounter) sum(t time.Time) (good, total uint64) {
+	r.ring.advance(t, r.clear)
+	for _, bucket := range r.buckets {
+		good += bucket.good
+		total += bucket.total
+	}
+	return
+}
+
+// clear resets the expired bucket at the given position.
+func (r *rollingCounter) clear(index int) {
+	r.buckets[index] = rollingBucket{}
+}
+
+// matchMethod returns true if the given method is present in the methods list.
+func matchMethod(method string, methods []string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// formatWindow formats the given window duration into a compact string, e.g: 5m, 1h, 3d.
+func formatWindow(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", d/time.Second)
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbio/st"
+)
+
+func TestSLO(t *testing.T) {
+	info, metrics := createMetrics()
+	defer metrics.Reset()
+
+	slo := NewSLO("api", 0.9)
+	slo.Latency = 300 * time.Millisecond
+	slo.Methods = []string{"GET"}
+
+	for x := 0; x < 8; x++ {
+		slo.Record(info)
+	}
+	info.Status = 500
+	slo.Record(info)
+	info.Status = 200
+	info.TimeEnd = info.TimeStart.Add(time.Second)
+	slo.Record(info)
+
+	info.Request.Method = "POST"
+	info.Status = 500
+	slo.Record(info)
+
+	now := info.TimeEnd
+	slo.Publish(metrics, now)
+	gauges := metrics.Snapshot().FloatGauges
+	st.Expect(t, gauges["slo.api.sli"], 0.8)
+	st.Expect(t, gauges["slo.api.budget"] < -0.99, true)
+	st.Expect(t, gauges["slo.api.burn_rate.5m"] > 1.99, true)
+	st.Expect(t, gauges["slo.api.burn_rate.3d"] > 1.99, true)
+
+	st.Expect(t, slo.BurnRate(5*time.Minute, now) > 1.99, true)
+	st.Expect(t, slo.BurnRate(5*time.Minute, now.Add(10*time.Minute)), float64(0))
+	st.Expect(t, slo.BurnRate(time.Hour, now.Add(10*time.Minute)) > 1.99, true)
+	st.Expect(t, slo.Budget(now) < -0.99, true)
+}
+
+func TestRollingCounter(t *testing.T) {
+	counter := newRollingCounter(time.Minute, 6)
+	now := time.Unix(0, 0)
+
+	counter.add(now, true)
+	counter.add(now.Add(10*time.Second), false)
+	good, total := counter.sum(now.Add(30 * time.Second))
+	st.Expect(t, good, uint64(1))
+	st.Expect(t, total, uint64(2))
+
+	good, total = counter.sum(now.Add(65 * time.Second))
+	st.Expect(t, good, uint64(0))
+	st.Expect(t, total, uint64(1))
+
+	good, total = counter.sum(now.Add(time.Hour))
+	st.Expect(t, total, uint64(0))
+}
+
+func TestFormatWindow(t *testing.T) {
+	st.Expect(t, formatWindow(5*time.Minute), "5m")
+	st.Expect(t, formatWindow(6*time.Hour), "6h")
+	st.Expect(t, formatWindow(72*time.Hour), "3d")
+	st.Expect(t, formatWindow(30*time.Second), "30s")
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import "time"
+
+// TimerCountSuffix defines the key suffix of the counter used to report the number of timed events.
+const TimerCountSuffix = ".count"
+
+// Timer implements a metric used to measure the duration of events.
+// Durations are recorded in milliseconds into an histogram, and timer
+// also counts the number of timed events and its rate per second.
+//
+// Timer is designed to be safety used by multiple goroutines.
+type Timer struct {
+	// counter stores the counter used to report the number of events.
+	counter *Counter
+	// histogram stores the histogram used to record the durations.
+	histogram *Histogram
+}
+
+// TimerContext represents a single timed event started via Timer.Start().
+type TimerContext struct {
+	timer *Timer
+	start time.Time
+}
+
+// Record records the given event duration.
+func (t *Timer) Record(d time.Duration) {
+	t.counter.Add()
+	t.histogram.RecordValue(int64(d / time.Millisecond))
+}
+
+// RecordExemplar records the given event duration, retaining it as exemplar
+// linked to the given trace ID.
+func (t *Timer) RecordExemplar(d time.Duration, traceID string) {
+	t.counter.Add()
+	t.histogram.RecordExemplar(int64(d/time.Millisecond), traceID)
+}
+
+// Time measures and records the execution time of the given function.
+func (t *Timer) Time(fn func()) {
+	start := time.Now()
+	fn()
+	t.Record(time.Since(start))
+}
+
+// Start starts timing a new event. Call Stop on the returned context to record it.
+func (t *Timer) Start() *TimerContext {
+	return &TimerContext{timer: t, start: time.Now()}
+}
+
+// Count returns the number of timed events.
+func (t *Timer) Count() uint64 {
+	return t.counter.Count()
+}
+
+// Stop records the time elapsed since the timed event was started
+// and returns its duration.
+func (c *TimerContext) Stop() time.Duration {
+	d := time.Since(c.start)
+	c.timer.Record(d)
+	return d
+}
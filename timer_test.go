@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbio/st"
+)
+
+func TestTimer(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+
+	timer := metrics.Timer("foo")
+	timer.Record(100 * time.Millisecond)
+	timer.Time(func() {})
+	timer.Start().Stop()
+	st.Expect(t, timer.Count(), uint64(3))
+	st.Expect(t, metrics.Timer("foo"), timer)
+
+	report := metrics.Snapshot()
+	st.Expect(t, report.Counters["foo.count"], uint64(3))
+	_, ok := report.Counters["foo"]
+	st.Expect(t, ok, false)
+	st.Expect(t, report.Gauges["foo.P999"], int64(100))
+	st.Expect(t, report.FloatGauges["foo.rate"] > 0, true)
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// TopKSize defines the number of heavy hitters tracked by the built-in top-k meters.
+// Defaults to 10.
+var TopKSize = 10
+
+// topKFactor defines the number of counters tracked per reported item,
+// used to improve the space-saving algorithm accuracy.
+const topKFactor = 4
+
+// TopKEntry represents a heavy hitter item tracked by TopK.
+type TopKEntry struct {
+	// Item stores the tracked item value.
+	Item string
+	// Count stores the estimated item count.
+	Count uint64
+	// Error stores the maximum overestimation of the item count.
+	Error uint64
+}
+
+// TopK implements a heavy hitters metric based on the space-saving algorithm,
+// which tracks the most frequent items using a bounded amount of memory.
+//
+// TopK is designed to be safety used by multiple goroutines.
+type TopK struct {
+	sync.Mutex
+	k       int
+	entries map[string]*TopKEntry
+}
+
+// NewTopK creates a new heavy hitters metric reporting the top k items.
+func NewTopK(k int) *TopK {
+	if k < 1 {
+		k = 1
+	}
+	return &TopK{k: k, entries: make(map[string]*TopKEntry, k*topKFactor)}
+}
+
+// Add registers a new occurrence of the given item.
+func (t *TopK) Add(item string) {
+	t.AddN(item, 1)
+}
+
+// AddN registers n occurrences of the given item.
+func (t *TopK) AddN(item string, n uint64) {
+	t.Lock()
+	defer t.Unlock()
+
+	if entry, ok := t.entries[item]; ok {
+		entry.Count += n
+		return
+	}
+	if len(t.entries) < t.k*topKFactor {
+		t.entries[item] = &TopKEntry{Item: item, Count: n}
+		return
+	}
+
+	// Replace the least frequent item, inheriting its count as error
+	var min *TopKEntry
+	for _, entry := range t.entries {
+		if min == nil || entry.Count < min.Count {
+			min = entry
+		}
+	}
+	delete(t.entries, min.Item)
+	t.entries[item] = &TopKEntry{Item: item, Count: min.Count + n, Error: min.Count}
+}
+
+// Top returns the top k items sorted by count in descending order.
+func (t *TopK) Top() []TopKEntry {
+	t.Lock()
+	entries := make([]TopKEntry, 0, len(t.entries))
+	for _, entry := range t.entries {
+		entries = append(entries, *entry)
+	}
+	t.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count == entries[j].Count {
+			return entries[i].Item < entries[j].Item
+		}
+		return entries[i].Count > entries[j].Count
+	})
+	if len(entries) > t.k {
+		entries = entries[:t.k]
+	}
+	return entries
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/nbio/st"
+)
+
+func TestTopK(t *testing.T) {
+	topk := NewTopK(2)
+	topk.AddN("foo", 100)
+	topk.AddN("bar", 50)
+	for x := 0; x < 20; x++ {
+		topk.Add(fmt.Sprintf("item-%d", x))
+	}
+	topk.Add("bar")
+
+	top := topk.Top()
+	st.Expect(t, len(top), 2)
+	st.Expect(t, top[0], TopKEntry{Item: "foo", Count: 100})
+	st.Expect(t, top[1], TopKEntry{Item: "bar", Count: 51})
+}
+
+func TestMeterTopK(t *testing.T) {
+	info, metrics := createMetrics()
+	defer metrics.Reset()
+
+	info.Request.URL = &url.URL{Path: "/foo"}
+	info.Request.RemoteAddr = "10.0.0.1:1234"
+	MeterTopPaths(info, metrics)
+	MeterTopClients(info, metrics)
+	MeterTopErrors(info, metrics)
+
+	info.Status = 500
+	MeterTopPaths(info, metrics)
+	MeterTopErrors(info, metrics)
+
+	report := metrics.Snapshot()
+	st.Expect(t, report.TopK["req.top.paths"], []TopKEntry{{Item: "/foo", Count: 2}})
+	st.Expect(t, report.TopK["req.top.clients"], []TopKEntry{{Item: "10.0.0.1", Count: 1}})
+	st.Expect(t, report.TopK["res.top.errors"], []TopKEntry{{Item: "/foo", Count: 1}})
+}
@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// WindowHistogram implements a rolling time window histogram built from time bucketed
+// sub-histograms, which can be queried at any moment to get the distribution of the
+// values recorded within the window, e.g: the P99 over the last 60 seconds.
+//
+// Unlike histograms, window histograms are preserved across publish cycles.
+// WindowHistogram is designed to be safety used by multiple goroutines.
+type WindowHistogram struct {
+	sync.Mutex
+	ring    ring
+	buckets []*hdrhistogram.Histogram
+}
+
+// NewWindowHistogram creates a new window histogram covering the given window
+// and split into sub-histograms of the given granularity.
+// Memory usage is proportional to the number of sub-histograms (window / granularity).
+func NewWindowHistogram(window, granularity time.Duration) *WindowHistogram {
+	size := int(window / granularity)
+	if size < 1 {
+		size = 1
+	}
+	return &WindowHistogram{
+		ring:    newRing(window, size),
+		buckets: make([]*hdrhistogram.Histogram, size),
+	}
+}
+
+// RecordValue records the given value.
+func (w *WindowHistogram) RecordValue(v int64) error {
+	return w.record(time.Now(), v)
+}
+
+// Histogram returns a new histogram merging the values recorded within the window.
+func (w *WindowHistogram) Histogram() *Histogram {
+	return w.merge(time.Now())
+}
+
+// ValueAtQuantile returns the value at the given quantile, between 0 and 100,
+// of the values recorded within the window.
+func (w *WindowHistogram) ValueAtQuantile(q float64) int64 {
+	return w.Histogram().ValueAtQuantile(q)
+}
+
+// Count returns the number of values recorded within the window.
+func (w *WindowHistogram) Count() int64 {
+	return w.Histogram().Count()
+}
+
+func (w *WindowHistogram) record(t time.Time, v int64) error {
+	w.Lock()
+	defer w.Unlock()
+	index := w.ring.advance(t, w.clear)
+	if w.buckets[index] == nil {
+		w.buckets[index] = hdrhistogram.New(1, HistogramMax, HistogramPrecision)
+	}
+	return w.buckets[index].RecordValue(v)
+}
+
+func (w *WindowHistogram) merge(t time.Time) *Histogram {
+	w.Lock()
+	defer w.Unlock()
+	w.ring.advance(t, w.clear)
+	merged := NewHistogram()
+	for _, bucket := range w.buckets {
+		if bucket != nil {
+			merged.hist.Merge(bucket)
+		}
+	}
+	return merged
+}
+
+// clear resets the expired sub-histogram at the given position, reusing its memory.
+func (w *WindowHistogram) clear(index int) {
+	if w.buckets[index] != nil {
+		w.buckets[index].Reset()
+	}
+}
+
+// ring implements the time bucketing of rolling windows,
+// mapping times into a fixed size ring of buckets.
+// Caller is responsible of synchronization.
+type ring struct {
+	// resolution stores the time span covered by each bucket.
+	resolution time.Duration
+	// size stores the number of buckets.
+	size int64
+	// last stores the absolute index of the latest used bucket.
+	last int64
+}
+
+// newRing creates a new ring for the given window split into size buckets.
+func newRing(window time.Duration, size int) ring {
+	resolution := window / time.Duration(size)
+	if resolution <= 0 {
+		resolution = 1
+	}
+	return ring{resolution: resolution, size: int64(size)}
+}
+
+// advance calls clear for every bucket expired up to the given time,
+// returning the bucket position for the given time.
+// Times older than the latest used bucket are mapped into it.
+func (r *ring) advance(t time.Time, clear func(int)) int {
+	index := t.UnixNano() / int64(r.resolution)
+	if index <= r.last {
+		return int(r.last % r.size)
+	}
+
+	from := r.last + 1
+	if index-from >= r.size {
+		from = index - r.size + 1
+	}
+	for x := from; x <= index; x++ {
+		clear(int(x % r.size))
+	}
+
+	r.last = index
+	return int(index % r.size)
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbio/st"
+)
+
+func TestWindowHistogram(t *testing.T) {
+	hist := NewWindowHistogram(time.Minute, 10*time.Second)
+	now := time.Unix(0, 0)
+
+	hist.record(now, 10)
+	hist.record(now.Add(15*time.Second), 20)
+	hist.record(now.Add(30*time.Second), 30)
+	st.Expect(t, hist.merge(now.Add(30*time.Second)).Count(), int64(3))
+	st.Expect(t, hist.merge(now.Add(30*time.Second)).ValueAtQuantile(100), int64(30))
+
+	// First sub-histogram expires
+	merged := hist.merge(now.Add(65 * time.Second))
+	st.Expect(t, merged.Count(), int64(2))
+	st.Expect(t, merged.ValueAtQuantile(50), int64(20))
+
+	// Whole window expires
+	st.Expect(t, hist.merge(now.Add(time.Hour)).Count(), int64(0))
+}
+
+func TestMetricsWindowHistogram(t *testing.T) {
+	metrics := NewMetrics()
+	defer metrics.Reset()
+
+	metrics.WindowHistogram("foo", time.Minute, time.Second).RecordValue(100)
+	metrics.Reset()
+
+	hist := metrics.WindowHistogram("foo", time.Minute, time.Second)
+	st.Expect(t, hist.Count(), int64(1))
+	st.Expect(t, hist.ValueAtQuantile(99), int64(100))
+
+	report := metrics.Snapshot()
+	st.Expect(t, report.Gauges["foo.P99"], int64(100))
+	st.Expect(t, report.Histograms["foo"].Count, int64(1))
+}